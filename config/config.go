@@ -0,0 +1,369 @@
+package config
+
+import (
+	"energy/domain/entity"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Settings are resolved in this order, each source overriding the previous one:
+// the defaults, the YAML file given by -config or ENERGY_CONFIG, the ENERGY_*
+// environment variables and the command line flags. Every setting has a flag,
+// its environment variable is the flag name in upper case with underscores and
+// the ENERGY_ prefix, e.g. -tip-selection and ENERGY_TIP_SELECTION.
+
+// Roles a process can run.
+const (
+	RoleNode   = "node"
+	RoleWallet = "wallet"
+	RoleBoth   = "both"
+)
+
+type Config struct {
+	// Subsystems run by the process: node, wallet or both
+	Role    string        `yaml:"role"`
+	Node    NodeConfig    `yaml:"node"`
+	Wallet  WalletConfig  `yaml:"wallet"`
+	Pubsub  PubsubConfig  `yaml:"pubsub"`
+	Genesis GenesisConfig `yaml:"genesis"`
+}
+
+type NodeConfig struct {
+	Port              string  `yaml:"port"`
+	TipSelection      string  `yaml:"tipSelection"`
+	TipSelectionAlpha float64 `yaml:"tipSelectionAlpha"`
+	// Cumulative weight a transaction needs to be confirmed
+	ConfirmationWeight int `yaml:"confirmationWeight"`
+	// Fraction of sampled tips that must approve a transaction to confirm it,
+	// zero disables confidence based confirmation
+	ConfirmationConfidence float64 `yaml:"confirmationConfidence"`
+	// Number of tip selections sampled to compute the confirmation confidence
+	ConfirmationSamples int `yaml:"confirmationSamples"`
+	// Directory of the on-disk store, empty keeps the ledger in memory only
+	DataDir string `yaml:"dataDir"`
+	// Proof of work difficulty added to the base difficulty of every transaction
+	PowDifficultyOffset int `yaml:"powDifficultyOffset"`
+	// Transactions per minute entering the pending pool above which the
+	// difficulty of submitted transactions rises, zero disables it
+	PowTargetInflow int `yaml:"powTargetInflow"`
+	// Maximum difficulty added when the inflow exceeds the target
+	PowMaxAdjustment int `yaml:"powMaxAdjustment"`
+	// Mine the PoW of transactions for clients that cannot afford it
+	PowService bool `yaml:"powService"`
+	// Number of proofs the PoW service mines at the same time
+	PowServiceWorkers int `yaml:"powServiceWorkers"`
+	// Number of proofs a client can request per hour
+	PowServiceQuota int `yaml:"powServiceQuota"`
+	// Nonces the PoW service tries before giving up, zero means no limit
+	PowServiceMaxIterations uint64 `yaml:"powServiceMaxIterations"`
+	// Base URL of the node API advertised to peers, which hand it to their
+	// wallets, e.g. http://10.0.0.2:8080. Empty keeps the node unadvertised.
+	PublicURL string `yaml:"publicUrl"`
+	// Addresses or CIDRs of the reverse proxies whose X-Forwarded-For header
+	// identifies clients, e.g. for the PoW service quota. Empty trusts none.
+	TrustedProxies []string `yaml:"trustedProxies"`
+}
+
+type WalletConfig struct {
+	Port string `yaml:"port"`
+	// Base URLs of the node APIs the wallet uses, e.g. http://10.0.0.2:8080.
+	// When the process also runs a node and none are given, the wallet uses
+	// the local node.
+	NodeURLs []string `yaml:"nodeUrls"`
+	// Number of goroutines mining the proof of work of a transaction
+	PowWorkers int `yaml:"powWorkers"`
+	// Nonces tried before giving up the proof of work, zero means no limit
+	PowMaxIterations uint64 `yaml:"powMaxIterations"`
+	// How a request picks its node among the healthy ones: random or latency
+	NodeSelection string `yaml:"nodeSelection"`
+	// Period between health checks of the nodes, zero disables them
+	NodeHealthInterval time.Duration `yaml:"nodeHealthInterval"`
+	// Add the nodes advertised by the peers of the known nodes to the pool
+	NodeDiscovery bool `yaml:"nodeDiscovery"`
+	// Other nodes a failed request is retried on
+	NodeRetries int `yaml:"nodeRetries"`
+}
+
+type PubsubConfig struct {
+	WalletCreateTopic   string `yaml:"walletCreateTopic"`
+	NewTransactionTopic string `yaml:"newTransactionTopic"`
+	// Service tag peers advertise and look for on mDNS
+	MdnsServiceTag string `yaml:"mdnsServiceTag"`
+	// Period between logs of the connected peers, zero disables them
+	PeerLogInterval time.Duration `yaml:"peerLogInterval"`
+}
+
+type GenesisConfig struct {
+	// YAML or JSON file defining the genesis, which must be equal on every
+	// node of a network. Empty uses the development genesis.
+	File string `yaml:"file"`
+}
+
+// developmentOriginAddress owns every token of the development genesis. Its
+// master seed is public, see loadtest/generate_transactions.py.
+const developmentOriginAddress = "100c8283eaefcbedcd4d330e83bda94f21687c35db5f5102743ca1215d153c3f"
+
+const envPrefix = "ENERGY_"
+
+func Default() Config {
+	return Config{
+		Role: RoleBoth,
+		Node: NodeConfig{
+			Port:                    "8080",
+			TipSelection:            "weighted-random-walk",
+			TipSelectionAlpha:       0.5,
+			ConfirmationWeight:      5,
+			ConfirmationConfidence:  0,
+			ConfirmationSamples:     20,
+			PowTargetInflow:         120,
+			PowMaxAdjustment:        3,
+			PowServiceWorkers:       2,
+			PowServiceQuota:         60,
+			PowServiceMaxIterations: 100000000,
+		},
+		Wallet: WalletConfig{
+			Port:               "8090",
+			PowWorkers:         runtime.NumCPU(),
+			NodeSelection:      "random",
+			NodeHealthInterval: 10 * time.Second,
+			NodeDiscovery:      true,
+			NodeRetries:        2,
+		},
+		Pubsub: PubsubConfig{
+			WalletCreateTopic:   "wallet-create-topic",
+			NewTransactionTopic: "new-transaction-topic",
+			MdnsServiceTag:      "Energy",
+			PeerLogInterval:     30 * time.Second,
+		},
+	}
+}
+
+// Load resolves the configuration from the command line arguments, without
+// the program name. It reports whether -print-config was given.
+func Load(args []string) (Config, bool, error) {
+	// A first pass only finds the configuration file, the flags are parsed
+	// again once the file and the environment are applied
+	var configPath string
+	var printConfig bool
+	scratch := Default()
+	firstPass := newFlagSet(&scratch, &configPath, &printConfig)
+	firstPass.SetOutput(io.Discard)
+	_ = firstPass.Parse(args)
+
+	if configPath == "" {
+		configPath = os.Getenv(envPrefix + "CONFIG")
+	}
+
+	config := Default()
+	if configPath != "" {
+		if err := decodeFile(configPath, &config); err != nil {
+			return config, false, err
+		}
+	}
+
+	if err := loadEnv(&config); err != nil {
+		return config, false, err
+	}
+
+	flagSet := newFlagSet(&config, &configPath, &printConfig)
+	if err := flagSet.Parse(args); err != nil {
+		return config, false, err
+	}
+
+	// Positional ports are kept for compatibility with older scripts
+	if flagSet.NArg() > 1 {
+		config.Node.Port = flagSet.Arg(0)
+		config.Wallet.Port = flagSet.Arg(1)
+	}
+
+	if err := resolveRole(&config); err != nil {
+		return config, false, err
+	}
+
+	return config, printConfig, nil
+}
+
+// RunsNode reports whether the process runs the node and the pubsub.
+func (c Config) RunsNode() bool {
+	return c.Role == RoleNode || c.Role == RoleBoth
+}
+
+// RunsWallet reports whether the process runs the wallet.
+func (c Config) RunsWallet() bool {
+	return c.Role == RoleWallet || c.Role == RoleBoth
+}
+
+func resolveRole(config *Config) error {
+	if config.Role != RoleNode && config.Role != RoleWallet && config.Role != RoleBoth {
+		return fmt.Errorf("unknown role %q, expected node, wallet or both", config.Role)
+	}
+
+	if config.RunsWallet() && len(config.Wallet.NodeURLs) == 0 {
+		if !config.RunsNode() {
+			return errors.New("a wallet without a local node needs node URLs")
+		}
+		config.Wallet.NodeURLs = []string{"http://localhost:" + config.Node.Port}
+	}
+
+	for i, nodeURL := range config.Wallet.NodeURLs {
+		normalized, err := NormalizeNodeURL(nodeURL)
+		if err != nil {
+			return err
+		}
+		config.Wallet.NodeURLs[i] = normalized
+	}
+
+	if config.Node.PublicURL != "" {
+		normalized, err := NormalizeNodeURL(config.Node.PublicURL)
+		if err != nil {
+			return err
+		}
+		config.Node.PublicURL = normalized
+	}
+	return nil
+}
+
+// NormalizeNodeURL checks that a node API base URL is an http or https URL
+// and removes its trailing slash.
+func NormalizeNodeURL(nodeURL string) (string, error) {
+	parsed, err := url.Parse(nodeURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("invalid node URL %q", nodeURL)
+	}
+	return strings.TrimSuffix(nodeURL, "/"), nil
+}
+
+// Print writes the configuration as YAML.
+func Print(w io.Writer, config Config) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// DevelopmentGenesis returns the genesis of the development network.
+func DevelopmentGenesis() entity.Genesis {
+	return entity.Genesis{
+		Timestamp: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Allocations: []entity.GenesisAllocation{
+			{Address: developmentOriginAddress, Amount: 1000000000 * entity.AmountUnit},
+		},
+	}
+}
+
+// LoadGenesis reads and validates the genesis file of the settings, or
+// returns the development genesis when there is none.
+func LoadGenesis(genesisCfg GenesisConfig) (entity.Genesis, error) {
+	if genesisCfg.File == "" {
+		return DevelopmentGenesis(), nil
+	}
+
+	var genesis entity.Genesis
+	if err := decodeFile(genesisCfg.File, &genesis); err != nil {
+		return genesis, err
+	}
+	if err := genesis.Validate(); err != nil {
+		return genesis, fmt.Errorf("invalid genesis file %s: %w", genesisCfg.File, err)
+	}
+	return genesis, nil
+}
+
+// decodeFile decodes a YAML file, which can also be JSON, refusing unknown
+// fields.
+func decodeFile(path string, value any) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(value); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	return nil
+}
+
+func loadEnv(config *Config) error {
+	for _, setting := range settings(config) {
+		name := envName(setting.name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setting.value.Set(value); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+func newFlagSet(config *Config, configPath *string, printConfig *bool) *flag.FlagSet {
+	flagSet := flag.NewFlagSet("energy", flag.ContinueOnError)
+	flagSet.StringVar(configPath, "config", *configPath, "YAML configuration file")
+	flagSet.BoolVar(printConfig, "print-config", false, "Print the effective configuration and exit")
+	for _, setting := range settings(config) {
+		flagSet.Var(setting.value, setting.name, setting.usage)
+	}
+	return flagSet
+}
+
+type setting struct {
+	name  string
+	usage string
+	value flag.Value
+}
+
+func settings(config *Config) []setting {
+	return []setting{
+		{"role", "Subsystems to run: node, wallet or both", (*stringValue)(&config.Role)},
+
+		{"node-port", "Port of the node API", (*stringValue)(&config.Node.Port)},
+		{"tip-selection", "Tip selection strategy: oldest, random or weighted-random-walk", (*stringValue)(&config.Node.TipSelection)},
+		{"tip-selection-alpha", "Cumulative weight bias of the weighted random walk", (*float64Value)(&config.Node.TipSelectionAlpha)},
+		{"confirmation-weight", "Cumulative weight needed to confirm a transaction", (*intValue)(&config.Node.ConfirmationWeight)},
+		{"confirmation-confidence", "Tip selection confidence needed to confirm a transaction, 0 disables it", (*float64Value)(&config.Node.ConfirmationConfidence)},
+		{"confirmation-samples", "Tip selections sampled to compute the confirmation confidence", (*intValue)(&config.Node.ConfirmationSamples)},
+		{"data-dir", "Directory where the node stores its ledger, empty keeps it in memory only", (*stringValue)(&config.Node.DataDir)},
+		{"pow-difficulty-offset", "Proof of work difficulty added to the base difficulty", (*intValue)(&config.Node.PowDifficultyOffset)},
+		{"pow-target-inflow", "Pending transactions per minute above which the proof of work difficulty rises, 0 disables it", (*intValue)(&config.Node.PowTargetInflow)},
+		{"pow-max-adjustment", "Maximum proof of work difficulty added under high inflow", (*intValue)(&config.Node.PowMaxAdjustment)},
+		{"pow-service", "Let clients that cannot afford it ask the node to mine their proof of work", (*boolValue)(&config.Node.PowService)},
+		{"pow-service-workers", "Proofs of work the node mines at the same time for clients", (*intValue)(&config.Node.PowServiceWorkers)},
+		{"pow-service-quota", "Proofs of work a client can ask the node for per hour", (*intValue)(&config.Node.PowServiceQuota)},
+		{"pow-service-max-iterations", "Nonces the node tries before giving up a client proof of work, 0 means no limit", (*uint64Value)(&config.Node.PowServiceMaxIterations)},
+		{"public-url", "Base URL of the node API advertised to peers and their wallets, empty keeps it unadvertised", (*stringValue)(&config.Node.PublicURL)},
+		{"trusted-proxies", "Comma separated addresses or CIDRs of the reverse proxies trusted to report client addresses, empty trusts none", (*stringsValue)(&config.Node.TrustedProxies)},
+
+		{"wallet-port", "Port of the wallet API", (*stringValue)(&config.Wallet.Port)},
+		{"node-urls", "Comma separated base URLs of the nodes the wallet uses, defaults to the local node", (*stringsValue)(&config.Wallet.NodeURLs)},
+		{"pow-workers", "Goroutines the wallet uses to mine the proof of work", (*intValue)(&config.Wallet.PowWorkers)},
+		{"pow-max-iterations", "Nonces the wallet tries before giving up the proof of work, 0 means no limit", (*uint64Value)(&config.Wallet.PowMaxIterations)},
+		{"node-selection", "How the wallet picks a node for each request: random or latency", (*stringValue)(&config.Wallet.NodeSelection)},
+		{"node-health-interval", "Period between health checks of the wallet nodes, 0 disables them", (*durationValue)(&config.Wallet.NodeHealthInterval)},
+		{"node-discovery", "Add the nodes advertised by the peers of the wallet nodes to its pool", (*boolValue)(&config.Wallet.NodeDiscovery)},
+		{"node-retries", "Other nodes a failed wallet request is retried on", (*intValue)(&config.Wallet.NodeRetries)},
+
+		{"wallet-create-topic", "Pubsub topic of wallet creations", (*stringValue)(&config.Pubsub.WalletCreateTopic)},
+		{"new-transaction-topic", "Pubsub topic of new transactions", (*stringValue)(&config.Pubsub.NewTransactionTopic)},
+		{"mdns-service-tag", "Service tag used to discover peers with mDNS", (*stringValue)(&config.Pubsub.MdnsServiceTag)},
+		{"peer-log-interval", "Period between logs of the connected peers, 0 disables them", (*durationValue)(&config.Pubsub.PeerLogInterval)},
+
+		{"genesis-file", "YAML or JSON genesis file shared by the nodes of the network, empty uses the development genesis", (*stringValue)(&config.Genesis.File)},
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// flag.Value adapters over the fields of Config, shared by the flags and the
+// environment variables.
+
+type stringValue string
+
+func (v *stringValue) Set(value string) error {
+	*v = stringValue(value)
+	return nil
+}
+
+func (v *stringValue) String() string { return string(*v) }
+
+// stringsValue holds a comma separated list.
+type stringsValue []string
+
+func (v *stringsValue) Set(value string) error {
+	*v = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*v = append(*v, item)
+		}
+	}
+	return nil
+}
+
+func (v *stringsValue) String() string { return strings.Join(*v, ",") }
+
+type intValue int
+
+func (v *intValue) Set(value string) error {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	*v = intValue(parsed)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type uint64Value uint64
+
+func (v *uint64Value) Set(value string) error {
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	*v = uint64Value(parsed)
+	return nil
+}
+
+func (v *uint64Value) String() string { return strconv.FormatUint(uint64(*v), 10) }
+
+type float64Value float64
+
+func (v *float64Value) Set(value string) error {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+	*v = float64Value(parsed)
+	return nil
+}
+
+func (v *float64Value) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+type boolValue bool
+
+func (v *boolValue) Set(value string) error {
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*v = boolValue(parsed)
+	return nil
+}
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+// IsBoolFlag lets the flag be given without a value.
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(parsed)
+	return nil
+}
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
@@ -0,0 +1,132 @@
+package entity
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Amount is a non-negative quantity of tokens stored as an integer number of
+// base units, with AmountDecimals decimal places.
+type Amount uint64
+
+const AmountDecimals = 8
+
+// AmountUnit is the number of base units in one token.
+const AmountUnit Amount = 100000000
+
+var (
+	ErrAmountOverflow  = errors.New("amount overflow")
+	ErrAmountUnderflow = errors.New("amount underflow")
+)
+
+// amountPattern is the grammar of a token quantity: digits, optional decimal
+// places and an optional exponent of up to three digits. Signs, fractions such
+// as "1/4" and prefixes such as "0x" are not amounts.
+var amountPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?([eE][+-]?[0-9]{1,3})?$`)
+
+// ParseAmount parses a decimal token quantity such as "12.5" or "1e-3". It
+// fails when the value is not a plain decimal number, is too large or has more
+// than AmountDecimals decimal places.
+func ParseAmount(value string) (Amount, error) {
+	trimmed := strings.TrimSpace(value)
+	if !amountPattern.MatchString(trimmed) {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	rat, ok := new(big.Rat).SetString(trimmed)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+
+	rat.Mul(rat, new(big.Rat).SetInt64(int64(AmountUnit)))
+	if !rat.IsInt() {
+		return 0, fmt.Errorf("amount %q has more than %d decimal places", value, AmountDecimals)
+	}
+	if !rat.Num().IsUint64() {
+		return 0, ErrAmountOverflow
+	}
+	return Amount(rat.Num().Uint64()), nil
+}
+
+// Add returns a + b or an error when the result does not fit in an Amount.
+func (a Amount) Add(b Amount) (Amount, error) {
+	if b > math.MaxUint64-a {
+		return 0, ErrAmountOverflow
+	}
+	return a + b, nil
+}
+
+// Sub returns a - b or an error when the result would be negative.
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if b > a {
+		return 0, ErrAmountUnderflow
+	}
+	return a - b, nil
+}
+
+// Mul returns a * n or an error when the result does not fit in an Amount.
+func (a Amount) Mul(n uint64) (Amount, error) {
+	if n != 0 && uint64(a) > math.MaxUint64/n {
+		return 0, ErrAmountOverflow
+	}
+	return a * Amount(n), nil
+}
+
+// String formats the amount in tokens without trailing zeros, e.g. "0.01".
+func (a Amount) String() string {
+	whole := strconv.FormatUint(uint64(a/AmountUnit), 10)
+	fraction := uint64(a % AmountUnit)
+	if fraction == 0 {
+		return whole
+	}
+	digits := fmt.Sprintf("%0*d", AmountDecimals, fraction)
+	return whole + "." + strings.TrimRight(digits, "0")
+}
+
+// MarshalText encodes the amount as its decimal string.
+func (a Amount) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText parses a decimal string such as "12.5".
+func (a *Amount) UnmarshalText(text []byte) error {
+	amount, err := ParseAmount(string(text))
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// MarshalJSON encodes the amount as an exact JSON number.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts the amount either as a JSON number or as a string.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+
+	value := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	}
+
+	amount, err := ParseAmount(value)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
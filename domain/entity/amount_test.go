@@ -0,0 +1,183 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Amount
+		wantErr bool
+	}{
+		{value: "0", want: 0},
+		{value: "1", want: AmountUnit},
+		{value: "12.5", want: 1250000000},
+		{value: " 0.01 ", want: 1000000},
+		{value: "0.00000001", want: 1},
+		{value: "0.000000010", want: 1},
+		{value: "0.000000001", wantErr: true},
+		{value: "1e-3", want: 100000},
+		{value: "1.5E2", want: 15000000000},
+		{value: "1e-9", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "-0.5", wantErr: true},
+		{value: "184467440737.09551615", want: math.MaxUint64},
+		{value: "184467440737.09551616", wantErr: true},
+		{value: "1e20", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "1,5", wantErr: true},
+		{value: "1/4", wantErr: true},
+		{value: "0x10", wantErr: true},
+		{value: "0b1", wantErr: true},
+		{value: "1_000", wantErr: true},
+		{value: "+1", wantErr: true},
+		{value: ".5", wantErr: true},
+		{value: "5.", wantErr: true},
+		{value: "1e", wantErr: true},
+		{value: "1e+2", want: 100 * AmountUnit},
+		{value: "1e1000000000", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseAmount(test.value)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseAmount(%q) = %d, want an error", test.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseAmount(%q): %v", test.value, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseAmount(%q) = %d, want %d", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseAmountOverflowError(t *testing.T) {
+	if _, err := ParseAmount("1e20"); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("ParseAmount(\"1e20\") error = %v, want %v", err, ErrAmountOverflow)
+	}
+}
+
+func TestAmountAdd(t *testing.T) {
+	tests := []struct {
+		a, b    Amount
+		want    Amount
+		wantErr error
+	}{
+		{a: 0, b: 0, want: 0},
+		{a: AmountUnit, b: 1, want: AmountUnit + 1},
+		{a: math.MaxUint64 - 1, b: 1, want: math.MaxUint64},
+		{a: math.MaxUint64, b: 1, wantErr: ErrAmountOverflow},
+		{a: 1, b: math.MaxUint64, wantErr: ErrAmountOverflow},
+		{a: math.MaxUint64, b: math.MaxUint64, wantErr: ErrAmountOverflow},
+	}
+
+	for _, test := range tests {
+		got, err := test.a.Add(test.b)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%d.Add(%d) error = %v, want %v", test.a, test.b, err, test.wantErr)
+			continue
+		}
+		if err == nil && got != test.want {
+			t.Errorf("%d.Add(%d) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestAmountSub(t *testing.T) {
+	tests := []struct {
+		a, b    Amount
+		want    Amount
+		wantErr error
+	}{
+		{a: 0, b: 0, want: 0},
+		{a: AmountUnit, b: AmountUnit, want: 0},
+		{a: AmountUnit, b: 1, want: AmountUnit - 1},
+		{a: math.MaxUint64, b: 1, want: math.MaxUint64 - 1},
+		{a: 0, b: 1, wantErr: ErrAmountUnderflow},
+		{a: AmountUnit, b: AmountUnit + 1, wantErr: ErrAmountUnderflow},
+	}
+
+	for _, test := range tests {
+		got, err := test.a.Sub(test.b)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%d.Sub(%d) error = %v, want %v", test.a, test.b, err, test.wantErr)
+			continue
+		}
+		if err == nil && got != test.want {
+			t.Errorf("%d.Sub(%d) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestAmountRoundTrip(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		want   string
+	}{
+		{amount: 0, want: "0"},
+		{amount: 1, want: "0.00000001"},
+		{amount: 1000000, want: "0.01"},
+		{amount: AmountUnit, want: "1"},
+		{amount: 1250000000, want: "12.5"},
+		{amount: math.MaxUint64, want: "184467440737.09551615"},
+	}
+
+	for _, test := range tests {
+		if got := test.amount.String(); got != test.want {
+			t.Errorf("Amount(%d).String() = %q, want %q", test.amount, got, test.want)
+		}
+
+		text, err := test.amount.MarshalText()
+		if err != nil {
+			t.Fatalf("MarshalText(%d): %v", test.amount, err)
+		}
+		var fromText Amount
+		if err := fromText.UnmarshalText(text); err != nil || fromText != test.amount {
+			t.Errorf("UnmarshalText(%q) = %d, %v, want %d", text, fromText, err, test.amount)
+		}
+
+		data, err := json.Marshal(test.amount)
+		if err != nil {
+			t.Fatalf("json.Marshal(%d): %v", test.amount, err)
+		}
+		if string(data) != test.want {
+			t.Errorf("json.Marshal(%d) = %s, want %s", test.amount, data, test.want)
+		}
+
+		// Amounts are accepted as JSON numbers and as strings
+		for _, encoded := range []string{string(data), `"` + test.want + `"`} {
+			var fromJSON Amount
+			if err := json.Unmarshal([]byte(encoded), &fromJSON); err != nil || fromJSON != test.amount {
+				t.Errorf("json.Unmarshal(%s) = %d, %v, want %d", encoded, fromJSON, err, test.amount)
+			}
+		}
+	}
+}
+
+func TestAmountUnmarshalJSONInField(t *testing.T) {
+	var value struct {
+		Token Amount
+	}
+
+	if err := json.Unmarshal([]byte(`{"Token": 2.5}`), &value); err != nil || value.Token != 250000000 {
+		t.Errorf("got %d, %v, want 250000000", value.Token, err)
+	}
+	if err := json.Unmarshal([]byte(`{"Token": null}`), &value); err != nil || value.Token != 250000000 {
+		t.Errorf("null changed the amount to %d, %v", value.Token, err)
+	}
+	for _, encoded := range []string{`{"Token": -1}`, `{"Token": "0.000000001"}`, `{"Token": 1e20}`, `{"Token": true}`} {
+		if err := json.Unmarshal([]byte(encoded), &value); err == nil {
+			t.Errorf("json.Unmarshal(%s) accepted an invalid amount", encoded)
+		}
+	}
+}
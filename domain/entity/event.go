@@ -0,0 +1,57 @@
+package entity
+
+import (
+	"slices"
+	"time"
+)
+
+// Event reports a change of the node as it happens.
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// Transaction added, confirmed, rejected or found in conflict
+	Transaction *Transaction `json:"transaction,omitempty"`
+	// Wallet created, or sender of the conflicting transactions
+	Address string `json:"address,omitempty"`
+	// Pending transactions of the sender marked as conflicting with the transaction
+	ConflictingIDs []string `json:"conflictingIds,omitempty"`
+	// Peer joined or left
+	Peer string `json:"peer,omitempty"`
+}
+
+const (
+	EventTransactionAdded     string = "transaction-added"
+	EventTransactionConfirmed string = "transaction-confirmed"
+	EventTransactionRejected  string = "transaction-rejected"
+	EventConflictDetected     string = "conflict-detected"
+	EventWalletCreated        string = "wallet-created"
+	EventPeerJoined           string = "peer-joined"
+	EventPeerLeft             string = "peer-left"
+)
+
+var eventTypes = []string{
+	EventTransactionAdded,
+	EventTransactionConfirmed,
+	EventTransactionRejected,
+	EventConflictDetected,
+	EventWalletCreated,
+	EventPeerJoined,
+	EventPeerLeft,
+}
+
+func IsValidEventType(eventType string) bool {
+	return slices.Contains(eventTypes, eventType)
+}
+
+func NewTransactionEvent(eventType string, transaction Transaction) Event {
+	return Event{Type: eventType, Time: time.Now(), Transaction: &transaction}
+}
+
+// InvolvesAddress reports whether the event concerns a wallet, as the sender
+// or recipient of its transaction or as its address.
+func (e Event) InvolvesAddress(address string) bool {
+	if e.Address == address {
+		return true
+	}
+	return e.Transaction != nil && (e.Transaction.From == address || e.Transaction.To == address)
+}
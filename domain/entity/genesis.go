@@ -0,0 +1,76 @@
+package entity
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Genesis defines the tokens every address owns before the first transaction.
+// All the nodes of a network must share it, its hash identifies the network.
+type Genesis struct {
+	// Creation time of the origin transactions
+	Timestamp   time.Time           `json:"timestamp" yaml:"timestamp"`
+	Allocations []GenesisAllocation `json:"allocations" yaml:"allocations"`
+}
+
+type GenesisAllocation struct {
+	Address string `json:"address" yaml:"address"`
+	Amount  Amount `json:"amount" yaml:"amount"`
+}
+
+func (g Genesis) Validate() error {
+	if len(g.Allocations) == 0 {
+		return errors.New("genesis needs at least one allocation")
+	}
+
+	seen := make(map[string]bool, len(g.Allocations))
+	for _, allocation := range g.Allocations {
+		if !IsValidAddress(allocation.Address) {
+			return fmt.Errorf("invalid genesis allocation address %q", allocation.Address)
+		}
+		if seen[allocation.Address] {
+			return fmt.Errorf("duplicated genesis allocation address %s", allocation.Address)
+		}
+		seen[allocation.Address] = true
+
+		if allocation.Amount == 0 {
+			return fmt.Errorf("empty genesis allocation to %s", allocation.Address)
+		}
+	}
+
+	_, err := g.Supply()
+	return err
+}
+
+// Supply returns the tokens allocated by the genesis.
+func (g Genesis) Supply() (Amount, error) {
+	var supply Amount
+	for _, allocation := range g.Allocations {
+		var err error
+		if supply, err = supply.Add(allocation.Amount); err != nil {
+			return 0, err
+		}
+	}
+	return supply, nil
+}
+
+// Hash returns the hex encoded SHA-256 hash of the timestamp and of the
+// allocations in the order they are listed.
+func (g Genesis) Hash() string {
+	var buffer bytes.Buffer
+
+	writeCanonicalField(&buffer, strconv.FormatInt(g.Timestamp.UnixNano(), 10))
+	writeCanonicalField(&buffer, strconv.Itoa(len(g.Allocations)))
+	for _, allocation := range g.Allocations {
+		writeCanonicalField(&buffer, allocation.Address)
+		writeCanonicalField(&buffer, allocation.Amount.String())
+	}
+
+	hash := sha256.Sum256(buffer.Bytes())
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Wallet keys are derived deterministically from the master seed:
+//
+//	seed (64 random bytes) -> SHA-256 -> Ed25519 private key -> public key -> address
+//
+// The address is the hex encoded public key, so nodes only need the address to
+// verify the signatures of a wallet and never see its seed.
+
+// SeedLength is the length in bytes of a wallet master seed.
+const SeedLength = 64
+
+// NewKeyPairFromSeed derives the Ed25519 key pair of a wallet from its hex
+// encoded master seed.
+func NewKeyPairFromSeed(seed string) (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	if !IsValidSeed(seed) {
+		return nil, nil, fmt.Errorf("invalid wallet seed")
+	}
+
+	seedBytes, _ := hex.DecodeString(seed)
+	keySeed := sha256.Sum256(seedBytes)
+	privateKey := ed25519.NewKeyFromSeed(keySeed[:])
+
+	return privateKey.Public().(ed25519.PublicKey), privateKey, nil
+}
+
+// AddressFromPublicKey returns the address owned by a public key.
+func AddressFromPublicKey(publicKey ed25519.PublicKey) string {
+	return hex.EncodeToString(publicKey)
+}
+
+// DeriveAddress returns the public address of the wallet with the given seed.
+func DeriveAddress(seed string) (string, error) {
+	publicKey, _, err := NewKeyPairFromSeed(seed)
+	if err != nil {
+		return "", err
+	}
+	return AddressFromPublicKey(publicKey), nil
+}
+
+func IsValidSeed(seed string) bool {
+	return len(seed) == 2*SeedLength && isHex(seed)
+}
+
+func isHex(value string) bool {
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
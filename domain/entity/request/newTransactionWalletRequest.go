@@ -1,9 +1,12 @@
 package request
 
+import "energy/domain/entity"
+
 type NewTransactionWalletRequest struct {
-	From  string  `json:"from"`
-	To    string  `json:"to"`
-	Token float64 `json:"token"`
-	Data  string  `json:"data"`
-	Type  string  `json:"type"`
+	Seed  string        `json:"seed"`
+	From  string        `json:"from"`
+	To    string        `json:"to"`
+	Token entity.Amount `json:"token"`
+	Data  string        `json:"data"`
+	Type  string        `json:"type"`
 }
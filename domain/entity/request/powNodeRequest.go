@@ -0,0 +1,9 @@
+package request
+
+import (
+	"energy/domain/entity"
+)
+
+type PowNodeRequest struct {
+	Transaction entity.Transaction `json:"transaction"`
+}
@@ -0,0 +1,5 @@
+package request
+
+type TransactionLookupRequest struct {
+	IDs []string `json:"ids"`
+}
@@ -0,0 +1,6 @@
+package response
+
+type DifficultyResponse struct {
+	Adjustment int `json:"adjustment"`
+	Inflow     int `json:"inflow"`
+}
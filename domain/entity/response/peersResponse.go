@@ -0,0 +1,5 @@
+package response
+
+type PeersResponse struct {
+	Peers []string `json:"peers"`
+}
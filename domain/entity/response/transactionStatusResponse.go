@@ -0,0 +1,17 @@
+package response
+
+import (
+	"energy/domain/entity"
+	"time"
+)
+
+// TransactionStatusResponse describes a transaction and its place in the DAG.
+type TransactionStatusResponse struct {
+	Transaction entity.Transaction `json:"transaction"`
+	Status      string             `json:"status"`
+	Parents     []string           `json:"parents"`
+	Approvers   []string           `json:"approvers"`
+	// Nil until the transaction is confirmed
+	TimestampConfirmed *time.Time `json:"timestampConfirmed"`
+	CumulativeWeight   int        `json:"cumulativeWeight"`
+}
@@ -0,0 +1,10 @@
+package response
+
+import "energy/domain/entity"
+
+type WalletResponse struct {
+	Address         string        `json:"address"`
+	Balance         entity.Amount `json:"balance"`
+	PendingOutgoing entity.Amount `json:"pendingOutgoing"`
+	Sequence        uint64        `json:"sequence"`
+}
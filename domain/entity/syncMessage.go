@@ -0,0 +1,28 @@
+package entity
+
+// SyncRequest is sent by a node over the "/energy" stream to catch up with a peer.
+type SyncRequest struct {
+	Type string   `json:"type"`
+	IDs  []string `json:"ids,omitempty"`
+}
+
+type SyncResponse struct {
+	Tips         []string      `json:"tips,omitempty"`
+	Transactions []Transaction `json:"transactions,omitempty"`
+	Info         *NodeInfo     `json:"info,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// NodeInfo is exchanged when two nodes connect.
+type NodeInfo struct {
+	// Nodes with different genesis hashes refuse each other
+	GenesisHash string `json:"genesisHash"`
+	// Public URL of the node API, empty when not advertised
+	PublicURL string `json:"publicUrl,omitempty"`
+}
+
+const (
+	SyncTips         string = "tips"
+	SyncTransactions string = "transactions"
+	SyncInfo         string = "info"
+)
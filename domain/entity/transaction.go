@@ -1,7 +1,12 @@
 package entity
 
 import (
-	"github.com/google/uuid"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
 	"time"
 )
 
@@ -10,33 +15,39 @@ type Transaction struct {
 	TimestampCreated   time.Time
 	TimestampAdded     time.Time
 	TimestampConfirmed time.Time
-	Token              float64
+	Token              Amount
 	Data               string
 	From               string
 	To                 string
 	Nonce              uint64
-	Fee                float64
+	Sequence           uint64
+	Fee                Amount
 	Status             string
 	Type               string
+	Parents            []string
+	PublicKey          string
+	Signature          string
 }
 
-func NewTransaction(from string, to string, token float64, data string, transactionType string, fee float64, nonce uint64) Transaction {
-	id := generateUniqueID()
-
-	return Transaction{
-		ID:                 id,
+func NewTransaction(from string, to string, token Amount, data string, transactionType string, fee Amount, nonce uint64, sequence uint64, parents []string) Transaction {
+	transaction := Transaction{
 		From:               from,
 		To:                 to,
 		Token:              token,
 		Data:               data,
 		Nonce:              nonce,
+		Sequence:           sequence,
 		Fee:                fee,
 		TimestampCreated:   time.Now(),
 		TimestampAdded:     time.Time{},
 		TimestampConfirmed: time.Time{},
 		Type:               transactionType,
+		Parents:            parents,
 		Status:             TransactionStatusPending,
 	}
+	transaction.UpdateID()
+
+	return transaction
 }
 
 func (t *Transaction) UpdateStatus(newStatus string) {
@@ -51,21 +62,116 @@ func (t *Transaction) UpdateTimestampConfirmed() {
 	t.TimestampConfirmed = time.Now()
 }
 
-func generateUniqueID() string {
-	u := uuid.New()
-	return u.String()
+// UpdateID sets the ID of the transaction to the hash of its contents. It must
+// be called again whenever a field of the canonical encoding changes.
+func (t *Transaction) UpdateID() {
+	t.ID = t.Hash()
+}
+
+// Hash returns the hex encoded SHA-256 hash of the canonical encoding.
+func (t *Transaction) Hash() string {
+	hash := sha256.Sum256(t.CanonicalEncoding())
+	return hex.EncodeToString(hash[:])
+}
+
+// CanonicalEncoding returns the deterministic byte representation of the
+// transaction fields covered by its ID and signature.
+func (t *Transaction) CanonicalEncoding() []byte {
+	return t.encode(true)
+}
+
+// PowPreimage returns the bytes the proof of work of the transaction is
+// computed on: the canonical encoding without the nonce, which is what the
+// proof of work searches for, and without the public key, which is given by
+// the sender address.
+func (t *Transaction) PowPreimage() []byte {
+	return t.encode(false)
+}
+
+func (t *Transaction) encode(canonical bool) []byte {
+	var buffer bytes.Buffer
+
+	writeCanonicalField(&buffer, t.From)
+	writeCanonicalField(&buffer, strconv.FormatUint(t.Sequence, 10))
+	writeCanonicalField(&buffer, t.To)
+	writeCanonicalField(&buffer, t.Token.String())
+	writeCanonicalField(&buffer, t.Data)
+	writeCanonicalField(&buffer, t.Type)
+	writeCanonicalField(&buffer, t.Fee.String())
+	if canonical {
+		writeCanonicalField(&buffer, strconv.FormatUint(t.Nonce, 10))
+	}
+	writeCanonicalField(&buffer, strconv.FormatInt(t.TimestampCreated.UnixNano(), 10))
+	writeCanonicalField(&buffer, strconv.Itoa(len(t.Parents)))
+	for _, parent := range t.Parents {
+		writeCanonicalField(&buffer, parent)
+	}
+	if canonical {
+		writeCanonicalField(&buffer, t.PublicKey)
+	}
+
+	return buffer.Bytes()
+}
+
+// Sign sets the public key of the transaction, updates its ID and signs its
+// canonical encoding.
+func (t *Transaction) Sign(privateKey ed25519.PrivateKey) {
+	t.PublicKey = hex.EncodeToString(privateKey.Public().(ed25519.PublicKey))
+	t.UpdateID()
+	t.Signature = hex.EncodeToString(ed25519.Sign(privateKey, t.CanonicalEncoding()))
+}
+
+func writeCanonicalField(buffer *bytes.Buffer, value string) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(value)))
+	buffer.Write(length[:])
+	buffer.WriteString(value)
 }
 
 func IsValidID(id string) bool {
-	_, err := uuid.Parse(id)
-	return err == nil
+	return len(id) == 2*sha256.Size && isHex(id)
+}
+
+// HasValidID checks that the ID of the transaction is the hash of its contents.
+func HasValidID(transaction *Transaction) bool {
+	return transaction.ID == transaction.Hash()
 }
 
 func IsValidAddress(address string) bool {
-	return len(address) == 64
+	return len(address) == 2*ed25519.PublicKeySize && isHex(address)
+}
+
+func IsSigned(transaction *Transaction) bool {
+	return transaction.PublicKey != "" && transaction.Signature != ""
 }
 
-func IsValidFee(fee float64, data string) bool {
+// IsValidSignature checks that the transaction is signed by the key that owns
+// its FROM address.
+func IsValidSignature(transaction *Transaction) bool {
+	publicKey, err := hex.DecodeString(transaction.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+
+	if AddressFromPublicKey(publicKey) != transaction.From {
+		return false
+	}
+
+	signature, err := hex.DecodeString(transaction.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return false
+	}
+
+	return ed25519.Verify(publicKey, transaction.CanonicalEncoding(), signature)
+}
+
+// TotalAmount returns the tokens the sender pays for the transaction, its
+// token plus its fee.
+func (t *Transaction) TotalAmount() (Amount, error) {
+	return t.Token.Add(t.Fee)
+}
+
+func IsValidFee(fee Amount, data string) bool {
 	return fee == CalculateFee(data)
 }
 
@@ -73,10 +179,16 @@ func IsValidTransactionType(transactionType string) bool {
 	return transactionType == TransactionTypeStandard || transactionType == TransactionTypeFast
 }
 
-func CalculateFee(transactionData string) float64 {
-	fee := float64(len(transactionData)) / 10.0
-	if fee < 1.0 {
-		fee = 1.0
+func IsValidTransactionStatus(status string) bool {
+	return status == TransactionStatusPending || status == TransactionStatusConfirmed ||
+		status == TransactionStatusConflicting || status == TransactionStatusRejected
+}
+
+// CalculateFee returns a tenth of a token per byte of data, one token at least.
+func CalculateFee(transactionData string) Amount {
+	fee := AmountUnit / 10 * Amount(len(transactionData))
+	if fee < AmountUnit {
+		fee = AmountUnit
 	}
 	return fee
 }
@@ -88,6 +200,8 @@ const (
 )
 
 const (
-	TransactionStatusPending   string = "pending"
-	TransactionStatusConfirmed string = "confirmed"
+	TransactionStatusPending     string = "pending"
+	TransactionStatusConfirmed   string = "confirmed"
+	TransactionStatusConflicting string = "conflicting"
+	TransactionStatusRejected    string = "rejected"
 )
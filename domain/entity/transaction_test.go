@@ -0,0 +1,91 @@
+package entity
+
+import (
+	"crypto/ed25519"
+	"strings"
+	"testing"
+)
+
+const (
+	testSeed      = "0e681a72ae2aa5378def2e5cd7a53cceebd353f54f7d662255443af4d12c9a2234203b95b1be12cdd1d7bf125b0f5c6d69ef78702849ab0aad37b90203798ef9"
+	testOtherSeed = "11111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111"
+	testAddress   = "100c8283eaefcbedcd4d330e83bda94f21687c35db5f5102743ca1215d153c3f"
+	testRecipient = "8501df062b55e6f938cf5c2c36849e8c11663f8f79e28bd1a99431d825792a44"
+)
+
+func testPrivateKey(t *testing.T, seed string) ed25519.PrivateKey {
+	t.Helper()
+	_, privateKey, err := NewKeyPairFromSeed(seed)
+	if err != nil {
+		t.Fatalf("NewKeyPairFromSeed: %v", err)
+	}
+	return privateKey
+}
+
+func TestIsValidSignature(t *testing.T) {
+	privateKey := testPrivateKey(t, testSeed)
+	otherKey := testPrivateKey(t, testOtherSeed)
+
+	tests := []struct {
+		name   string
+		tamper func(transaction *Transaction)
+		want   bool
+	}{
+		{name: "signed by the owner", tamper: func(*Transaction) {}, want: true},
+		{name: "signed by another key", tamper: func(transaction *Transaction) { transaction.Sign(otherKey) }},
+		{name: "public key of another wallet", tamper: func(transaction *Transaction) {
+			transaction.PublicKey = AddressFromPublicKey(otherKey.Public().(ed25519.PublicKey))
+		}},
+		{name: "unsigned", tamper: func(transaction *Transaction) { transaction.Signature = "" }},
+		{name: "signature not hex", tamper: func(transaction *Transaction) { transaction.Signature = strings.Repeat("z", 2*ed25519.SignatureSize) }},
+		{name: "token changed", tamper: func(transaction *Transaction) { transaction.Token++ }},
+		{name: "recipient changed", tamper: func(transaction *Transaction) { transaction.To = testAddress }},
+		{name: "data changed", tamper: func(transaction *Transaction) { transaction.Data = "tampered" }},
+		{name: "sequence changed", tamper: func(transaction *Transaction) { transaction.Sequence++ }},
+		{name: "nonce changed", tamper: func(transaction *Transaction) { transaction.Nonce++ }},
+		{name: "parent added", tamper: func(transaction *Transaction) {
+			transaction.Parents = append(transaction.Parents, strings.Repeat("0", 64))
+		}},
+		{name: "status changed", tamper: func(transaction *Transaction) { transaction.UpdateStatus(TransactionStatusConfirmed) }, want: true},
+	}
+
+	for _, test := range tests {
+		transaction := NewTransaction(testAddress, testRecipient, AmountUnit, "hello", TransactionTypeStandard, 0, 7, 1, []string{strings.Repeat("a", 64)})
+		transaction.Sign(privateKey)
+		test.tamper(&transaction)
+
+		if got := IsValidSignature(&transaction); got != test.want {
+			t.Errorf("%s: IsValidSignature = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestHasValidID(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(transaction *Transaction)
+		want   bool
+	}{
+		{name: "unchanged", change: func(*Transaction) {}, want: true},
+		{name: "parent replaced", change: func(transaction *Transaction) { transaction.Parents[0] = strings.Repeat("b", 64) }},
+		{name: "parents reordered", change: func(transaction *Transaction) {
+			transaction.Parents[0], transaction.Parents[1] = transaction.Parents[1], transaction.Parents[0]
+		}},
+		{name: "parent dropped", change: func(transaction *Transaction) { transaction.Parents = transaction.Parents[:1] }},
+		{name: "token changed", change: func(transaction *Transaction) { transaction.Token++ }},
+		{name: "parent replaced and ID updated", change: func(transaction *Transaction) {
+			transaction.Parents[0] = strings.Repeat("b", 64)
+			transaction.UpdateID()
+		}, want: true},
+		{name: "timestamp added", change: func(transaction *Transaction) { transaction.UpdateTimestampAdded() }, want: true},
+	}
+
+	for _, test := range tests {
+		transaction := NewTransaction(testAddress, testRecipient, AmountUnit, "", TransactionTypeStandard, 0, 0, 1, []string{strings.Repeat("a", 64), strings.Repeat("c", 64)})
+		test.change(&transaction)
+
+		if got := HasValidID(&transaction); got != test.want {
+			t.Errorf("%s: HasValidID = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -5,42 +5,95 @@ import (
 	"sync"
 )
 
+// Wallet is an account of the ledger. Sequence is the highest sequence of the
+// transactions sent from the account that were accepted, the next one should
+// carry Sequence + 1.
+// TransactionHistory holds the confirmed transactions sent or received by the
+// account, in the order they were confirmed.
 type Wallet struct {
-	Seed               string
-	Balance            float64
+	Address            string
+	Balance            Amount
+	Sequence           uint64
 	TransactionHistory []Transaction
 	Mu                 sync.RWMutex // Mutex for thread safety
 }
 
-func NewWallet(seed string) *Wallet {
+func NewWallet(address string) *Wallet {
 	return &Wallet{
-		Seed: seed,
+		Address: address,
 	}
 }
 
-func (w *Wallet) IncreaseBalance(token float64) {
+func (w *Wallet) GetBalance() Amount {
+	w.Mu.RLock()
+	defer w.Mu.RUnlock()
+
+	return w.Balance
+}
+
+func (w *Wallet) IncreaseBalance(token Amount) error {
 	w.Mu.Lock()
 	defer w.Mu.Unlock()
 
-	w.Balance += token
+	balance, err := w.Balance.Add(token)
+	if err != nil {
+		return err
+	}
+	w.Balance = balance
+
+	return nil
 }
 
-func (w *Wallet) DecreaseBalance(token float64) error {
+func (w *Wallet) DecreaseBalance(token Amount) error {
 	w.Mu.Lock()
 	defer w.Mu.Unlock()
 
-	if w.Balance >= token {
-		w.Balance -= token
-	} else {
+	balance, err := w.Balance.Sub(token)
+	if err != nil {
 		return fmt.Errorf("insufficient balance")
 	}
+	w.Balance = balance
 
 	return nil
 }
 
+func (w *Wallet) GetSequence() uint64 {
+	w.Mu.RLock()
+	defer w.Mu.RUnlock()
+
+	return w.Sequence
+}
+
+func (w *Wallet) IncrementSequence() {
+	w.Mu.Lock()
+	defer w.Mu.Unlock()
+
+	w.Sequence++
+}
+
+// RaiseSequence reports whether the sequence was lower than the given one.
+func (w *Wallet) RaiseSequence(sequence uint64) bool {
+	w.Mu.Lock()
+	defer w.Mu.Unlock()
+
+	if sequence <= w.Sequence {
+		return false
+	}
+	w.Sequence = sequence
+	return true
+}
+
 func (w *Wallet) AddTransactionToHistory(transaction *Transaction) {
 	w.Mu.Lock()
 	defer w.Mu.Unlock()
 
 	w.TransactionHistory = append(w.TransactionHistory, *transaction)
 }
+
+// GetTransactionHistory returns a copy of the transaction history.
+func (w *Wallet) GetTransactionHistory() []Transaction {
+	w.Mu.RLock()
+	defer w.Mu.RUnlock()
+
+	return append([]Transaction(nil), w.TransactionHistory...)
+}
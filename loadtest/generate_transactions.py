@@ -10,12 +10,17 @@ import binascii
 from concurrent.futures import ThreadPoolExecutor
 import uuid
 
+# Master seed and address of the origin wallet of the test network
+ORIGIN_SEED = "0e681a72ae2aa5378def2e5cd7a53cceebd353f54f7d662255443af4d12c9a2234203b95b1be12cdd1d7bf125b0f5c6d69ef78702849ab0aad37b90203798ef9"
+ORIGIN_ADDRESS = "100c8283eaefcbedcd4d330e83bda94f21687c35db5f5102743ca1215d153c3f"
+
 
 def generate_random_transaction():
     transaction_types = ["transaction-standard", "transaction-fast"]
 
     return {
-        "from": "9a27ad25d050b690b05d38e1cf20c71e8c5314cff5a936efc024a2b5e9b07f04",
+        "seed": ORIGIN_SEED,
+        "from": ORIGIN_ADDRESS,
         "to": generate_master_seed(),
         "token": round(random.uniform(0, 0.1), 4),
         "data": generate_random_json(),
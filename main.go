@@ -2,64 +2,115 @@ package main
 
 import (
 	"context"
+	"energy/config"
 	"energy/node"
 	"energy/pubsub"
 	"energy/wallet"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 )
 
-var ctx, cancel = context.WithCancel(context.Background())
+// ctx is done when the process is asked to terminate
+var ctx, cancel = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+// stops holds the Stop function of every started subsystem
+var stops []func(context.Context) error
+
+// shutdownTimeout bounds the time the subsystems get to stop
+const shutdownTimeout = 10 * time.Second
 
 func main() {
 	defer cancel()
 
-	var nodePort string
-	var walletPort string
-	if len(os.Args) > 2 {
-		nodePort = os.Args[1]
-		walletPort = os.Args[2]
-	} else {
-		log.Printf("No ports specified. Using default ports %s", "8080 / 8090")
-		nodePort = "8080"
-		walletPort = "8090"
+	cfg, printConfig, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	log.Println("Initializing Energy...")
+	if printConfig {
+		if err := config.Print(os.Stdout, cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	pubsubInputImpl := node.PubsubInputImpl{}
+	log.Printf("Initializing Energy as %s...", cfg.Role)
 
-	pubsubOutputImpl := pubsub.PubsubOutputImpl{}
+	if cfg.RunsNode() {
+		genesis, err := config.LoadGenesis(cfg.Genesis)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	// Node DI & Start
+		pubsubInputImpl := node.PubsubInputImpl{}
 
-	node.NewNodeDI(pubsubInputImpl)
+		pubsubOutputImpl := pubsub.PubsubOutputImpl{}
 
-	node.StartNode(pubsubOutputImpl, nodePort)
+		// Node DI & Start
 
-	// Pubsub DI & Start
+		node.NewNodeDI(pubsubInputImpl)
 
-	pubsub.NewPubSubDI(pubsubOutputImpl)
+		err = node.StartNode(ctx, pubsubOutputImpl, cfg.Node, genesis)
+		if err != nil {
+			log.Fatal(err)
+		}
+		stops = append(stops, node.StopNode)
 
-	pubsub.StartPubsub(pubsubInputImpl)
+		// Pubsub DI & Start
+
+		pubsub.NewPubSubDI(pubsubOutputImpl)
+
+		err = pubsub.StartPubsub(ctx, pubsubInputImpl, cfg.Pubsub)
+		stops = append(stops, pubsub.StopPubsub)
+		if err != nil {
+			log.Println("Error starting pubsub:", err)
+			shutdown()
+			os.Exit(1)
+		}
+	}
 
 	// Wallet Start
 
-	wallet.StartWallet(walletPort, nodePort)
+	if cfg.RunsWallet() {
+		err = wallet.StartWallet(cfg.Wallet)
+		if err != nil {
+			log.Println("Error starting wallet:", err)
+			shutdown()
+			os.Exit(1)
+		}
+		stops = append(stops, wallet.StopWallet)
+	}
 
 	handleExit()
 }
 
 func handleExit() {
-	interruptChan := make(chan os.Signal, 1)
-	signal.Notify(interruptChan, os.Interrupt)
-
-	select {
-	case <-interruptChan:
-		fmt.Println("Received interrupt signal. Shutting down...")
-	case <-ctx.Done():
-		fmt.Println("Shutting down...")
+	<-ctx.Done()
+	fmt.Println("Received termination signal. Shutting down...")
+
+	shutdown()
+}
+
+// shutdown stops the started subsystems in the reverse order they were
+// started in.
+func shutdown() {
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	var errs []error
+	for i := len(stops) - 1; i >= 0; i-- {
+		errs = append(errs, stops[i](shutdownCtx))
+	}
+	if err := errors.Join(errs...); err != nil {
+		log.Println("Error shutting down:", err)
 	}
 }
@@ -0,0 +1,125 @@
+package node
+
+import (
+	"energy/domain/entity"
+	"log"
+	"sort"
+	"sync"
+)
+
+// Transactions are confirmed following the tangle security model: a pending
+// transaction becomes confirmed once its cumulative weight reaches the
+// configured threshold or, when enabled, once enough repeated tip selections
+// end on tips that approve it. Tokens are only transferred at that moment.
+//
+// Confirmation is a local decision. The confidence comes from random tip
+// selections, so two nodes holding the same DAG can confirm a transaction at
+// different times. Conflict resolution does not depend on that time as long as
+// every member of the conflict set has arrived (see conflicts.go).
+
+// ledgerMu serializes changes to balances, pending spends and transaction
+// statuses.
+var ledgerMu sync.Mutex
+
+// confirmTransactions confirms the pending transactions that reached the
+// confirmation threshold. The caller must hold the ledger mutex.
+func confirmTransactions() {
+	candidates := pendingTransactions.GetTransactions()
+
+	// Confirm older transactions first so every node applies transfers in the same order
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].TimestampCreated.Equal(candidates[j].TimestampCreated) {
+			return candidates[i].ID < candidates[j].ID
+		}
+		return candidates[i].TimestampCreated.Before(candidates[j].TimestampCreated)
+	})
+
+	var confidences map[string]float64
+	if nodeConfig.ConfirmationConfidence > 0 {
+		confidences = confirmationConfidences(nodeConfig.ConfirmationSamples)
+	}
+
+	for _, candidate := range candidates {
+		weightReached := dag.getCumulativeWeight(candidate.ID) >= nodeConfig.ConfirmationWeight
+		confidenceReached := confidences != nil && confidences[candidate.ID] >= nodeConfig.ConfirmationConfidence
+		if !weightReached && !confidenceReached {
+			continue
+		}
+
+		// Resolving an earlier conflict may have rejected or updated the candidate
+		candidate, ok := pendingTransactions.GetTransaction(candidate.ID)
+		if !ok {
+			continue
+		}
+
+		if candidate.Status == entity.TransactionStatusConflicting {
+			resolveConflict(candidate.From)
+			if candidate, ok = pendingTransactions.GetTransaction(candidate.ID); !ok {
+				continue
+			}
+		}
+
+		if err := confirmTransaction(candidate); err != nil {
+			log.Printf("Error confirming transaction %s, rejecting it: %v", candidate.ID, err)
+			rejectTransaction(candidate)
+		}
+	}
+}
+
+// confirmTransaction transfers the tokens of a transaction and saves it as
+// confirmed. The saved status is what confirms it for good, the balances are
+// derived from it after a restart. A failing transfer leaves the balances
+// unchanged.
+func confirmTransaction(transaction entity.Transaction) error {
+	if err := performTokenInterchange(transaction.From, transaction.To, transaction.Token, transaction.Fee); err != nil {
+		return err
+	}
+
+	if err := dag.ConfirmTransaction(transaction.ID); err != nil {
+		revertTokenInterchange(transaction.From, transaction.To, transaction.Token, transaction.Fee)
+		return err
+	}
+
+	deletePendingTransaction(transaction.ID)
+	pendingSpends.RemoveSpend(&transaction)
+
+	confirmedTransaction, _ := dag.getTransactionByID(transaction.ID)
+	recordConfirmation(confirmedTransaction)
+
+	// Add the confirmed transaction to the history of the sender and of the
+	// recipient, the transfer made sure both wallets exist
+	if err := walletStore.AddTransactionToHistory(transaction.From, &confirmedTransaction); err != nil {
+		log.Printf("Error adding transaction %s to the history of %s: %v", transaction.ID, transaction.From, err)
+	}
+	if transaction.To != transaction.From {
+		if err := walletStore.AddTransactionToHistory(transaction.To, &confirmedTransaction); err != nil {
+			log.Printf("Error adding transaction %s to the history of %s: %v", transaction.ID, transaction.To, err)
+		}
+	}
+
+	return nil
+}
+
+// confirmationConfidences runs the tip selection the given number of times and
+// returns, for every pending transaction, the fraction of selected tips that
+// directly or indirectly approve it. The selections are random, the result
+// differs between runs and between nodes.
+func confirmationConfidences(samples int) map[string]float64 {
+	approvals := make(map[string]int)
+	selectedTips := 0
+
+	for i := 0; i < samples; i++ {
+		for _, tip := range tipSelector.SelectTips() {
+			selectedTips++
+			for _, id := range dag.getPendingAncestors(tip.ID) {
+				approvals[id]++
+			}
+		}
+	}
+
+	confidences := make(map[string]float64, len(approvals))
+	for id, count := range approvals {
+		confidences[id] = float64(count) / float64(selectedTips)
+	}
+	return confidences
+}
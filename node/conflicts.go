@@ -0,0 +1,149 @@
+package node
+
+import (
+	"energy/domain/entity"
+	"log"
+	"sort"
+)
+
+// When the pending spends of a sender exceed its confirmed balance, or when
+// two pending transactions of the sender carry the same sequence, all of its
+// pending transactions form a conflict set and are marked as conflicting. The
+// set is resolved when one of its transactions reaches the confirmation
+// threshold: transactions are ranked by sequence, ties broken by the lowest ID,
+// and admitted while the balance of the sender covers them and no transaction
+// with the same sequence was admitted before. The rest are rejected, for good.
+//
+// The ranking only depends on the transactions themselves, not on the weights
+// of the DAG of this node or on when the transactions arrived. Nodes holding
+// the same members and the same confirmed balance of the sender when the set
+// is resolved reject the same transactions. A transaction that arrives after
+// its set was resolved cannot displace the transactions already admitted, so
+// double spends only resolve the same way everywhere when they reach the nodes
+// before one of them is confirmed.
+
+var pendingSpends = NewPendingSpendStore()
+
+// detectConflict registers the spend of a new transaction and marks it and the
+// other pending transactions of its sender as conflicting when they cannot all
+// be paid. The caller must hold the ledger mutex.
+func detectConflict(transaction *entity.Transaction) {
+	var balance entity.Amount
+	if fromWallet, exists := walletStore.GetWallet(transaction.From); exists {
+		balance = fromWallet.GetBalance()
+	}
+
+	if !canPaySpend(transaction, balance) || reusesPendingSequence(transaction) {
+		conflictingIDs := pendingSpends.GetSpendIDs(transaction.From)
+		log.Printf("Transaction %s conflicts with %d pending transactions of %s", transaction.ID, len(conflictingIDs), transaction.From)
+
+		transaction.UpdateStatus(entity.TransactionStatusConflicting)
+		for _, id := range conflictingIDs {
+			updateTransactionStatus(id, entity.TransactionStatusConflicting)
+		}
+
+		event := entity.NewTransactionEvent(entity.EventConflictDetected, *transaction)
+		event.Address = transaction.From
+		event.ConflictingIDs = conflictingIDs
+		events.Publish(event)
+	}
+
+	if err := pendingSpends.AddSpend(transaction); err != nil {
+		log.Printf("Error adding spend of transaction %s: %v", transaction.ID, err)
+	}
+}
+
+// canPaySpend reports whether the balance covers a new transaction on top of
+// the pending spends of its sender. Amounts that overflow cannot be paid.
+func canPaySpend(transaction *entity.Transaction, balance entity.Amount) bool {
+	pendingOutgoing, err := pendingSpends.GetPendingOutgoing(transaction.From)
+	if err != nil {
+		return false
+	}
+	amount, err := transaction.TotalAmount()
+	if err != nil {
+		return false
+	}
+	required, err := pendingOutgoing.Add(amount)
+	if err != nil {
+		return false
+	}
+	return required <= balance
+}
+
+// reusesPendingSequence reports whether another pending transaction of the
+// sender carries the sequence of a new transaction.
+func reusesPendingSequence(transaction *entity.Transaction) bool {
+	for _, id := range pendingSpends.GetSpendIDs(transaction.From) {
+		pending, ok := pendingTransactions.GetTransaction(id)
+		if ok && id != transaction.ID && pending.Sequence == transaction.Sequence {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveConflict admits the pending transactions of an address with the
+// lowest sequences that its balance can pay for and rejects the rest. The
+// caller must hold the ledger mutex.
+func resolveConflict(address string) {
+	members := make([]entity.Transaction, 0)
+	for _, id := range pendingSpends.GetSpendIDs(address) {
+		if transaction, ok := pendingTransactions.GetTransaction(id); ok {
+			members = append(members, transaction)
+		}
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Sequence == members[j].Sequence {
+			return members[i].ID < members[j].ID
+		}
+		return members[i].Sequence < members[j].Sequence
+	})
+
+	var balance entity.Amount
+	if fromWallet, exists := walletStore.GetWallet(address); exists {
+		balance = fromWallet.GetBalance()
+	}
+
+	admittedSequences := make(map[uint64]bool, len(members))
+	rejected := 0
+	for _, member := range members {
+		amount, err := member.TotalAmount()
+		if err == nil && amount <= balance && !admittedSequences[member.Sequence] {
+			balance -= amount
+			admittedSequences[member.Sequence] = true
+			updateTransactionStatus(member.ID, entity.TransactionStatusPending)
+		} else {
+			rejectTransaction(member)
+			rejected++
+		}
+	}
+
+	log.Printf("Resolved conflict of %s: %d admitted, %d rejected", address, len(members)-rejected, rejected)
+}
+
+// rejectTransaction removes a transaction from the pending pool and marks it
+// as rejected in the DAG. The caller must hold the ledger mutex.
+func rejectTransaction(transaction entity.Transaction) {
+	if err := dag.UpdateTransactionStatus(transaction.ID, entity.TransactionStatusRejected); err != nil {
+		log.Printf("Error rejecting transaction %s: %v", transaction.ID, err)
+	} else {
+		transaction.UpdateStatus(entity.TransactionStatusRejected)
+		events.Publish(entity.NewTransactionEvent(entity.EventTransactionRejected, transaction))
+	}
+
+	deletePendingTransaction(transaction.ID)
+	pendingSpends.RemoveSpend(&transaction)
+}
+
+func updateTransactionStatus(id string, status string) {
+	if err := dag.UpdateTransactionStatus(id, status); err != nil {
+		log.Printf("Error updating status of transaction %s: %v", id, err)
+	}
+
+	if transaction, ok := pendingTransactions.GetTransaction(id); ok {
+		transaction.UpdateStatus(status)
+		pendingTransactions.AddTransaction(transaction)
+	}
+}
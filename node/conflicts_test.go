@@ -0,0 +1,139 @@
+package node
+
+import (
+	"energy/config"
+	"energy/domain/entity"
+	"testing"
+	"time"
+)
+
+// newTestLedger resets the ledger of the node to the genesis of the test
+// addresses, A owning 10 tokens and B owning 1.
+func newTestLedger(t *testing.T, confirmationWeight int) {
+	t.Helper()
+
+	nodeConfig = config.NodeConfig{ConfirmationWeight: confirmationWeight}
+	store = NewMemoryStore()
+	pendingTransactions = NewPendingTransactions()
+	pendingSpends = NewPendingSpendStore()
+
+	err := initGenesis(entity.Genesis{
+		Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Allocations: []entity.GenesisAllocation{
+			{Address: testAddressA, Amount: 10 * entity.AmountUnit},
+			{Address: testAddressB, Amount: entity.AmountUnit},
+		},
+	})
+	if err != nil {
+		t.Fatalf("initGenesis: %v", err)
+	}
+	initDAG()
+}
+
+// ledgerStatuses returns the status of the given transactions in the DAG.
+func ledgerStatuses(ids []string) map[string]string {
+	statuses := make(map[string]string, len(ids))
+	for _, id := range ids {
+		transaction, _ := dag.getTransactionByID(id)
+		statuses[id] = transaction.Status
+	}
+	return statuses
+}
+
+func TestConflictResolutionIgnoresArrivalOrder(t *testing.T) {
+	newTestLedger(t, 3)
+	root := originTransaction.ID
+
+	// A spends 6 tokens twice with sequence 1 and once with sequence 2 out of
+	// its 10 tokens. Two chains of B approve different spends, so the spend
+	// reaching the confirmation threshold first depends on the arrival order.
+	first := entity.NewTransaction(testAddressA, testAddressB, 6*entity.AmountUnit, "first", entity.TransactionTypeStandard, 0, 0, 1, []string{root})
+	double := entity.NewTransaction(testAddressA, testAddressB, 6*entity.AmountUnit, "double", entity.TransactionTypeStandard, 0, 0, 1, []string{root})
+	second := entity.NewTransaction(testAddressA, testAddressB, 6*entity.AmountUnit, "second", entity.TransactionTypeStandard, 0, 0, 2, []string{root})
+	spends := []entity.Transaction{first, double, second}
+
+	chains := make([][]entity.Transaction, 0, len(spends))
+	sequence := uint64(0)
+	for _, spend := range spends {
+		sequence++
+		approver := entity.NewTransaction(testAddressB, testAddressA, 0, "", entity.TransactionTypeStandard, 0, 0, sequence, []string{spend.ID})
+		sequence++
+		tip := entity.NewTransaction(testAddressB, testAddressA, 0, "", entity.TransactionTypeStandard, 0, 0, sequence, []string{approver.ID})
+		chains = append(chains, []entity.Transaction{approver, tip})
+	}
+
+	ids := make([]string, 0, len(spends))
+	for _, spend := range spends {
+		ids = append(ids, spend.ID)
+	}
+
+	// The winner has the lowest sequence, then the lowest ID
+	winner, loser := first, double
+	if double.ID < first.ID {
+		winner, loser = double, first
+	}
+	want := map[string]string{
+		winner.ID: entity.TransactionStatusConfirmed,
+		loser.ID:  entity.TransactionStatusRejected,
+		second.ID: entity.TransactionStatusRejected,
+	}
+
+	for _, order := range [][]int{{0, 1, 2}, {1, 0, 2}, {2, 1, 0}, {2, 0, 1}} {
+		newTestLedger(t, 3)
+
+		ledgerMu.Lock()
+		for _, i := range order {
+			acceptSyncedTransaction(spends[i])
+		}
+		for _, i := range order {
+			for _, transaction := range chains[i] {
+				acceptSyncedTransaction(transaction)
+			}
+		}
+		ledgerMu.Unlock()
+
+		got := ledgerStatuses(ids)
+		for id, status := range want {
+			if got[id] != status {
+				t.Errorf("order %v: status of %s = %q, want %q", order, id, got[id], status)
+			}
+		}
+
+		wallet, _ := walletStore.GetWallet(testAddressA)
+		if wallet.GetBalance() != 4*entity.AmountUnit {
+			t.Errorf("order %v: balance of A = %s, want 4", order, wallet.GetBalance())
+		}
+	}
+}
+
+func TestConflictResolutionAdmitsWhatTheBalanceCovers(t *testing.T) {
+	newTestLedger(t, 2)
+	root := originTransaction.ID
+
+	spends := []entity.Transaction{
+		entity.NewTransaction(testAddressA, testAddressB, 4*entity.AmountUnit, "", entity.TransactionTypeStandard, 0, 0, 1, []string{root}),
+		entity.NewTransaction(testAddressA, testAddressB, 4*entity.AmountUnit, "", entity.TransactionTypeStandard, 0, 0, 2, []string{root}),
+		entity.NewTransaction(testAddressA, testAddressB, 4*entity.AmountUnit, "", entity.TransactionTypeStandard, 0, 0, 3, []string{root}),
+	}
+
+	ledgerMu.Lock()
+	for _, i := range []int{2, 1, 0} {
+		acceptSyncedTransaction(spends[i])
+	}
+	// Approving the first and the last spend makes the weights of the DAG favor
+	// them over the second one
+	approver := entity.NewTransaction(testAddressB, testAddressA, 0, "", entity.TransactionTypeStandard, 0, 0, 1, []string{spends[0].ID, spends[2].ID})
+	acceptSyncedTransaction(approver)
+	ledgerMu.Unlock()
+
+	got := ledgerStatuses([]string{spends[0].ID, spends[1].ID, spends[2].ID})
+	if got[spends[0].ID] != entity.TransactionStatusConfirmed {
+		t.Errorf("sequence 1 is %q, want confirmed", got[spends[0].ID])
+	}
+	if got[spends[1].ID] != entity.TransactionStatusPending {
+		t.Errorf("sequence 2 is %q, want pending", got[spends[1].ID])
+	}
+	if got[spends[2].ID] != entity.TransactionStatusRejected {
+		t.Errorf("sequence 3 is %q, want rejected", got[spends[2].ID])
+	}
+}
@@ -3,37 +3,284 @@ package node
 import (
 	"energy/domain/entity"
 	"fmt"
+	"sort"
 	"sync" // Import the sync package
 )
 
 type DAG struct {
 	Transactions map[string]entity.Transaction
-	mu           sync.Mutex // Add a mutex field
+	parents      map[string][]string        // Transaction ID -> IDs of the transactions it approves
+	approvers    map[string][]string        // Transaction ID -> IDs of the transactions approving it
+	weights      map[string]int             // Transaction ID -> cumulative weight
+	tips         map[string]bool            // IDs of the transactions no valid transaction approves yet
+	latest       string                     // ID of the last transaction added
+	sequences    map[string]map[uint64]bool // Sender address -> sequences of its confirmed transactions
+	index        TransactionIndex           // Secondary indexes of the transactions
+	mu           sync.Mutex                 // Add a mutex field
 }
 
 var dag DAG
 
+// originTransaction is the root of the DAG, originTransactions also holds the
+// transactions crediting the genesis allocations
+var originTransaction entity.Transaction
+var originTransactions []entity.Transaction
+
+func newDAG() DAG {
+	return DAG{
+		Transactions: make(map[string]entity.Transaction),
+		parents:      make(map[string][]string),
+		approvers:    make(map[string][]string),
+		weights:      make(map[string]int),
+		tips:         make(map[string]bool),
+		sequences:    make(map[string]map[uint64]bool),
+		index:        newTransactionIndex(),
+	}
+}
+
 func initDAG() {
-	dag = DAG{Transactions: make(map[string]entity.Transaction)}
-
-	// Origin transaction
-	dag.addTransaction(
-		entity.NewTransaction(
-			"9a27ad25d050b690b05d38e1cf20c71e8c5314cff5a936efc024a2b5e9b07f04",
-			"9a27ad25d050b690b05d38e1cf20c71e8c5314cff5a936efc024a2b5e9b07f04",
-			100000000,
-			"",
-			entity.TransactionTypeOrigin,
-			0.0,
-			0.0,
-		),
+	dag = newDAG()
+
+	for _, transaction := range originTransactions {
+		dag.addTransaction(transaction)
+	}
+}
+
+// newOriginTransactions derives the origin transactions from the genesis. The
+// first one is the root of the DAG, it commits to the genesis hash and moves
+// no tokens. Each of the others approves it and credits an allocation. Origin
+// transactions have no sender.
+func newOriginTransactions() []entity.Transaction {
+	supply, _ := genesis.Supply()
+
+	root := newOriginTransaction("", supply, genesisHash, nil)
+	transactions := []entity.Transaction{root}
+	for _, allocation := range genesis.Allocations {
+		transactions = append(transactions, newOriginTransaction(allocation.Address, allocation.Amount, "", []string{root.ID}))
+	}
+	return transactions
+}
+
+func newOriginTransaction(address string, token entity.Amount, data string, parents []string) entity.Transaction {
+	transaction := entity.NewTransaction(
+		"",
+		address,
+		token,
+		data,
+		entity.TransactionTypeOrigin,
+		0,
+		0,
+		0,
+		parents,
 	)
+	// The genesis timestamp lets every node derive the same origin transaction IDs
+	transaction.TimestampCreated = genesis.Timestamp
+	transaction.UpdateID()
+	transaction.UpdateStatus(entity.TransactionStatusConfirmed)
+	transaction.TimestampConfirmed = genesis.Timestamp
+	return transaction
 }
 
 func (dag *DAG) addTransaction(transaction entity.Transaction) {
 	dag.mu.Lock()         // Lock the mutex before modifying the map
 	defer dag.mu.Unlock() // Ensure the mutex is unlocked after this function exits
+
+	_, exists := dag.Transactions[transaction.ID]
+	dag.addTransactionLocked(transaction)
+	persistTransaction(transaction)
+
+	if !exists {
+		events.Publish(entity.NewTransactionEvent(entity.EventTransactionAdded, transaction))
+	}
+}
+
+// restoreTransaction adds a transaction loaded from the store. Parents must be
+// restored before their approvers.
+func (dag *DAG) restoreTransaction(transaction entity.Transaction) {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+
+	dag.addTransactionLocked(transaction)
+}
+
+func (dag *DAG) addTransactionLocked(transaction entity.Transaction) {
+	if _, exists := dag.Transactions[transaction.ID]; !exists {
+		dag.parents[transaction.ID] = transaction.Parents
+		for _, parent := range transaction.Parents {
+			dag.approvers[parent] = append(dag.approvers[parent], transaction.ID)
+		}
+		dag.updateCumulativeWeightsLocked(transaction.ID)
+		dag.latest = transaction.ID
+	}
+
+	dag.putTransactionLocked(transaction)
+}
+
+// putTransactionLocked stores a new or updated transaction and keeps the
+// indexes and the tips in step. The caller must hold the DAG mutex.
+func (dag *DAG) putTransactionLocked(transaction entity.Transaction) {
+	if previous, exists := dag.Transactions[transaction.ID]; exists {
+		dag.index.remove(previous)
+	}
 	dag.Transactions[transaction.ID] = transaction
+	dag.index.add(transaction)
+
+	if transaction.Status == entity.TransactionStatusConfirmed && transaction.From != "" {
+		if dag.sequences[transaction.From] == nil {
+			dag.sequences[transaction.From] = make(map[uint64]bool)
+		}
+		dag.sequences[transaction.From][transaction.Sequence] = true
+	}
+
+	dag.refreshTipLocked(transaction.ID)
+	for _, parent := range dag.parents[transaction.ID] {
+		dag.refreshTipLocked(parent)
+	}
+}
+
+// refreshTipLocked records whether a transaction is a tip: it was not
+// rejected and no transaction that was not rejected approves it. The caller
+// must hold the DAG mutex.
+func (dag *DAG) refreshTipLocked(id string) {
+	transaction, exists := dag.Transactions[id]
+	if exists && transaction.Status != entity.TransactionStatusRejected && len(dag.getValidApproversLocked(id)) == 0 {
+		dag.tips[id] = true
+	} else {
+		delete(dag.tips, id)
+	}
+}
+
+func (dag *DAG) hasTransaction(id string) bool {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+	_, ok := dag.Transactions[id]
+	return ok
+}
+
+// hasConfirmedSequence reports whether a confirmed transaction of the sender
+// carries the given sequence.
+func (dag *DAG) hasConfirmedSequence(from string, sequence uint64) bool {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+	return dag.sequences[from][sequence]
+}
+
+// countTransactions returns the number of transactions in the DAG.
+func (dag *DAG) countTransactions() int {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+	return len(dag.Transactions)
+}
+
+// countTips returns the number of transactions that no other transaction
+// approves yet.
+func (dag *DAG) countTips() int {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+	return len(dag.getTipsLocked())
+}
+
+// getParents returns the IDs of the transactions approved by the given transaction.
+func (dag *DAG) getParents(id string) []string {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+	return append([]string(nil), dag.parents[id]...)
+}
+
+// getApprovers returns the IDs of the transactions that approve the given transaction.
+func (dag *DAG) getApprovers(id string) []string {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+	return append([]string(nil), dag.approvers[id]...)
+}
+
+// getTipsLocked returns the IDs of the transactions that no other transaction
+// approves yet. The caller must hold the DAG mutex.
+func (dag *DAG) getTipsLocked() []string {
+	tips := make([]string, 0, len(dag.tips))
+	for id := range dag.tips {
+		tips = append(tips, id)
+	}
+	return tips
+}
+
+// getValidApproversLocked returns the approvers of a transaction that were not
+// rejected. The caller must hold the DAG mutex.
+func (dag *DAG) getValidApproversLocked(id string) []string {
+	approvers := make([]string, 0, len(dag.approvers[id]))
+	for _, approver := range dag.approvers[id] {
+		if dag.Transactions[approver].Status != entity.TransactionStatusRejected {
+			approvers = append(approvers, approver)
+		}
+	}
+	return approvers
+}
+
+// updateCumulativeWeightsLocked adds the weight of a new transaction to itself
+// and to the transactions it directly or indirectly approves, stopping at
+// confirmed transactions. Their weight no longer matters, and a transaction
+// reaches the confirmation threshold no later than its approvers, so the
+// ancestors of a confirmed transaction are confirmed or rejected too. The walk
+// only covers the part of the DAG that is not confirmed yet instead of going
+// back to the origin. The caller must hold the DAG mutex.
+func (dag *DAG) updateCumulativeWeightsLocked(id string) {
+	dag.weights[id] = 1
+
+	visited := map[string]bool{id: true}
+	queue := append([]string(nil), dag.parents[id]...)
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if visited[current] || dag.Transactions[current].Status == entity.TransactionStatusConfirmed {
+			continue
+		}
+		visited[current] = true
+		dag.weights[current]++
+		queue = append(queue, dag.parents[current]...)
+	}
+}
+
+// getCumulativeWeight returns the number of transactions that directly or
+// indirectly approve the given transaction, plus one for the transaction itself.
+// The weight of a confirmed transaction stops growing.
+func (dag *DAG) getCumulativeWeight(id string) int {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+	return dag.weights[id]
+}
+
+// getPendingAncestors returns the given transaction and every pending or
+// conflicting transaction it directly or indirectly approves.
+func (dag *DAG) getPendingAncestors(id string) []string {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+
+	ancestors := make([]string, 0)
+	visited := make(map[string]bool)
+	queue := []string{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		status := dag.Transactions[current].Status
+		if visited[current] || (status != entity.TransactionStatusPending && status != entity.TransactionStatusConflicting) {
+			continue
+		}
+		visited[current] = true
+		ancestors = append(ancestors, current)
+		queue = append(queue, dag.parents[current]...)
+	}
+	return ancestors
+}
+
+func (dag *DAG) getTransactions() map[string]entity.Transaction {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+
+	transactions := make(map[string]entity.Transaction, len(dag.Transactions))
+	for id, transaction := range dag.Transactions {
+		transactions[id] = transaction
+	}
+	return transactions
 }
 
 func (dag *DAG) getTransactionByID(id string) (entity.Transaction, bool) {
@@ -52,10 +299,76 @@ func (dag *DAG) ConfirmTransaction(transactionID string) error {
 		return fmt.Errorf("transaction with ID %s not found", transactionID)
 	}
 
+	if _transaction.Status == entity.TransactionStatusConfirmed {
+		return fmt.Errorf("transaction with ID %s already confirmed", transactionID)
+	}
+
 	_transaction.UpdateStatus(entity.TransactionStatusConfirmed)
 	_transaction.UpdateTimestampConfirmed()
 
-	dag.Transactions[transactionID] = _transaction
+	dag.putTransactionLocked(_transaction)
+	persistTransaction(_transaction)
+
+	events.Publish(entity.NewTransactionEvent(entity.EventTransactionConfirmed, _transaction))
 
 	return nil
 }
+
+func (dag *DAG) UpdateTransactionStatus(transactionID string, status string) error {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+
+	_transaction, ok := dag.Transactions[transactionID]
+	if !ok {
+		return fmt.Errorf("transaction with ID %s not found", transactionID)
+	}
+
+	_transaction.UpdateStatus(status)
+
+	dag.putTransactionLocked(_transaction)
+	persistTransaction(_transaction)
+
+	return nil
+}
+
+// sortTopologically orders transactions so that every transaction comes after
+// its parents, oldest first among the ones ready at the same time.
+func sortTopologically(transactions []entity.Transaction) []entity.Transaction {
+	byID := make(map[string]entity.Transaction, len(transactions))
+	for _, transaction := range transactions {
+		byID[transaction.ID] = transaction
+	}
+
+	missingParents := make(map[string]int, len(transactions))
+	approvers := make(map[string][]string)
+	ready := make([]entity.Transaction, 0)
+	for _, transaction := range transactions {
+		for _, parent := range transaction.Parents {
+			if _, ok := byID[parent]; ok {
+				missingParents[transaction.ID]++
+				approvers[parent] = append(approvers[parent], transaction.ID)
+			}
+		}
+		if missingParents[transaction.ID] == 0 {
+			ready = append(ready, transaction)
+		}
+	}
+
+	sorted := make([]entity.Transaction, 0, len(transactions))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool {
+			return ready[i].TimestampCreated.Before(ready[j].TimestampCreated)
+		})
+		current := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, current)
+
+		for _, approver := range approvers[current.ID] {
+			missingParents[approver]--
+			if missingParents[approver] == 0 {
+				ready = append(ready, byID[approver])
+			}
+		}
+	}
+	return sorted
+}
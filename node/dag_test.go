@@ -0,0 +1,67 @@
+package node
+
+import (
+	"energy/domain/entity"
+	"slices"
+	"testing"
+)
+
+func TestDAGTipsFollowRejections(t *testing.T) {
+	newTestLedger(t, 5)
+	allocationA, allocationB := originTransactions[1].ID, originTransactions[2].ID
+
+	child := entity.NewTransaction(testAddressB, testAddressA, 0, "", entity.TransactionTypeStandard, 0, 0, 1, []string{allocationA, allocationB})
+	dag.addTransaction(child)
+
+	dag.mu.Lock()
+	tips := dag.getTipsLocked()
+	dag.mu.Unlock()
+	if !slices.Equal(tips, []string{child.ID}) {
+		t.Fatalf("tips = %v, want only %s", tips, child.ID)
+	}
+
+	// Rejecting the only approver turns its parents back into tips
+	if err := dag.UpdateTransactionStatus(child.ID, entity.TransactionStatusRejected); err != nil {
+		t.Fatalf("UpdateTransactionStatus: %v", err)
+	}
+
+	dag.mu.Lock()
+	tips = dag.getTipsLocked()
+	dag.mu.Unlock()
+	slices.Sort(tips)
+	want := []string{allocationA, allocationB}
+	slices.Sort(want)
+	if !slices.Equal(tips, want) {
+		t.Errorf("tips = %v, want %v", tips, want)
+	}
+}
+
+func TestCumulativeWeightStopsAtConfirmedTransactions(t *testing.T) {
+	newTestLedger(t, 5)
+	allocationA := originTransactions[1].ID
+
+	first := entity.NewTransaction(testAddressB, testAddressA, 0, "", entity.TransactionTypeStandard, 0, 0, 1, []string{allocationA})
+	dag.addTransaction(first)
+	second := entity.NewTransaction(testAddressB, testAddressA, 0, "", entity.TransactionTypeStandard, 0, 0, 2, []string{first.ID})
+	dag.addTransaction(second)
+
+	if weight := dag.getCumulativeWeight(first.ID); weight != 2 {
+		t.Errorf("weight of the pending parent = %d, want 2", weight)
+	}
+	if weight := dag.getCumulativeWeight(allocationA); weight != 1 {
+		t.Errorf("weight of the confirmed origin transaction = %d, want 1", weight)
+	}
+
+	if err := dag.ConfirmTransaction(first.ID); err != nil {
+		t.Fatalf("ConfirmTransaction: %v", err)
+	}
+	third := entity.NewTransaction(testAddressB, testAddressA, 0, "", entity.TransactionTypeStandard, 0, 0, 3, []string{second.ID})
+	dag.addTransaction(third)
+
+	if weight := dag.getCumulativeWeight(second.ID); weight != 2 {
+		t.Errorf("weight of the pending parent = %d, want 2", weight)
+	}
+	if weight := dag.getCumulativeWeight(first.ID); weight != 2 {
+		t.Errorf("weight of the confirmed ancestor = %d, want it to stay 2", weight)
+	}
+}
@@ -0,0 +1,137 @@
+package node
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// The proof of work difficulty of a transaction is the base difficulty of the
+// pow package raised by an adjustment. The adjustment of a node is the
+// configured offset plus one leading zero for every doubling of the inflow into
+// the pending pool over the target inflow, up to a maximum. Transactions from
+// peers are checked with the configured offset only, since every node observes
+// a different inflow.
+//
+// A wallet mines for the adjustment it read from /node/difficulty, which may
+// rise before the transaction is submitted. A submitted transaction is accepted
+// with the lowest adjustment the node advertised during the current or the
+// previous inflow window, when it is lower than the current one.
+
+// inflowWindow is the period over which the pending pool inflow is measured.
+const inflowWindow = time.Minute
+
+// InflowMonitor counts the transactions added to the pending pool recently.
+type InflowMonitor struct {
+	mu       sync.Mutex
+	arrivals []time.Time
+}
+
+var inflowMonitor = &InflowMonitor{}
+
+// AdvertisedAdjustments remembers the lowest adjustment advertised during the
+// current and the previous inflow window.
+type AdvertisedAdjustments struct {
+	mu       sync.Mutex
+	window   int64
+	current  int
+	previous int
+}
+
+var advertisedAdjustments = &AdvertisedAdjustments{current: math.MaxInt, previous: math.MaxInt}
+
+func (aa *AdvertisedAdjustments) Record(adjustment int) {
+	aa.mu.Lock()
+	defer aa.mu.Unlock()
+
+	aa.rollLocked(time.Now())
+	aa.current = min(aa.current, adjustment)
+}
+
+// Lowest returns the lowest adjustment advertised during the current and the
+// previous window, math.MaxInt when none was.
+func (aa *AdvertisedAdjustments) Lowest() int {
+	aa.mu.Lock()
+	defer aa.mu.Unlock()
+
+	aa.rollLocked(time.Now())
+	return min(aa.current, aa.previous)
+}
+
+func (aa *AdvertisedAdjustments) rollLocked(now time.Time) {
+	window := now.UnixNano() / int64(inflowWindow)
+	switch window - aa.window {
+	case 0:
+		return
+	case 1:
+		aa.previous = aa.current
+	default:
+		aa.previous = math.MaxInt
+	}
+	aa.current = math.MaxInt
+	aa.window = window
+}
+
+func (im *InflowMonitor) RecordArrival() {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	im.arrivals = append(im.arrivals, time.Now())
+	im.pruneLocked()
+}
+
+// GetInflow returns the number of transactions added during the last window.
+func (im *InflowMonitor) GetInflow() int {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	im.pruneLocked()
+	return len(im.arrivals)
+}
+
+func (im *InflowMonitor) pruneLocked() {
+	cutoff := time.Now().Add(-inflowWindow)
+	expired := 0
+	for expired < len(im.arrivals) && im.arrivals[expired].Before(cutoff) {
+		expired++
+	}
+	im.arrivals = im.arrivals[expired:]
+}
+
+// baseDifficultyAdjustment returns the adjustment applied to transactions
+// received from peers.
+func baseDifficultyAdjustment() int {
+	return nodeConfig.PowDifficultyOffset
+}
+
+// currentDifficultyAdjustment returns the adjustment applied to the
+// transactions submitted to this node.
+func currentDifficultyAdjustment() int {
+	return baseDifficultyAdjustment() + inflowAdjustment(inflowMonitor.GetInflow())
+}
+
+// advertiseDifficultyAdjustment returns the current adjustment and remembers
+// that a wallet may mine for it.
+func advertiseDifficultyAdjustment() int {
+	adjustment := currentDifficultyAdjustment()
+	advertisedAdjustments.Record(adjustment)
+	return adjustment
+}
+
+// acceptedDifficultyAdjustment returns the adjustment a submitted transaction
+// must meet, the current one or a lower one advertised recently.
+func acceptedDifficultyAdjustment() int {
+	return min(currentDifficultyAdjustment(), advertisedAdjustments.Lowest())
+}
+
+func inflowAdjustment(inflow int) int {
+	if nodeConfig.PowTargetInflow <= 0 || inflow <= nodeConfig.PowTargetInflow {
+		return 0
+	}
+
+	adjustment := int(math.Log2(float64(inflow)/float64(nodeConfig.PowTargetInflow))) + 1
+	if adjustment > nodeConfig.PowMaxAdjustment {
+		adjustment = nodeConfig.PowMaxAdjustment
+	}
+	return adjustment
+}
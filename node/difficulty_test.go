@@ -0,0 +1,37 @@
+package node
+
+import (
+	"energy/config"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAcceptedDifficultyAdjustmentKeepsAdvertisedOnes(t *testing.T) {
+	nodeConfig = config.NodeConfig{PowDifficultyOffset: 1, PowTargetInflow: 2, PowMaxAdjustment: 3}
+	inflowMonitor = &InflowMonitor{}
+	advertisedAdjustments = &AdvertisedAdjustments{current: math.MaxInt, previous: math.MaxInt}
+
+	if adjustment := advertiseDifficultyAdjustment(); adjustment != 1 {
+		t.Fatalf("advertised adjustment = %d, want 1", adjustment)
+	}
+
+	// The inflow doubles twice over the target while the wallet mines
+	for i := 0; i < 5; i++ {
+		inflowMonitor.RecordArrival()
+	}
+	if adjustment := currentDifficultyAdjustment(); adjustment != 3 {
+		t.Fatalf("current adjustment = %d, want 3", adjustment)
+	}
+	if adjustment := acceptedDifficultyAdjustment(); adjustment != 1 {
+		t.Errorf("accepted adjustment = %d, want the advertised 1", adjustment)
+	}
+
+	// Advertised adjustments are forgotten after the previous window
+	advertisedAdjustments.mu.Lock()
+	advertisedAdjustments.rollLocked(time.Now().Add(2 * inflowWindow))
+	advertisedAdjustments.mu.Unlock()
+	if lowest := advertisedAdjustments.Lowest(); lowest != math.MaxInt {
+		t.Errorf("lowest adjustment after two windows = %d, want none", lowest)
+	}
+}
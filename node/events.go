@@ -0,0 +1,192 @@
+package node
+
+import (
+	"energy/domain/entity"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The node publishes an event whenever a transaction is added, confirmed or
+// rejected, a conflict is detected, a wallet is created or a peer joins or
+// leaves. Clients follow them as Server-Sent Events on /node/events. Events
+// are only delivered while a client is connected, and a client that does not
+// keep up is disconnected rather than slowing the node down.
+
+// eventBuffer is the number of events waiting for a slow client before it is
+// disconnected.
+const eventBuffer = 256
+
+// eventKeepAlive is the interval of the comments keeping idle streams open
+// through proxies.
+const eventKeepAlive = 15 * time.Second
+
+type EventBus struct {
+	subscribers map[*EventSubscriber]bool
+	closed      bool
+	mu          sync.Mutex
+}
+
+// EventFilter selects the events of a subscriber. Empty filters match every
+// event, events without a transaction do not match a transaction type and
+// events without an address do not match an address.
+type EventFilter struct {
+	Types           []string
+	Address         string
+	TransactionType string
+}
+
+type EventSubscriber struct {
+	// Closed when the subscriber is dropped or the node stops
+	Events chan entity.Event
+	filter EventFilter
+}
+
+var events = NewEventBus()
+
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[*EventSubscriber]bool)}
+}
+
+// Subscribe returns a subscriber receiving the events matching the filter,
+// false once the bus is closed.
+func (b *EventBus) Subscribe(filter EventFilter) (*EventSubscriber, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, false
+	}
+	subscriber := &EventSubscriber{Events: make(chan entity.Event, eventBuffer), filter: filter}
+	b.subscribers[subscriber] = true
+	return subscriber, true
+}
+
+func (b *EventBus) Unsubscribe(subscriber *EventSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers[subscriber] {
+		delete(b.subscribers, subscriber)
+		close(subscriber.Events)
+	}
+}
+
+// Publish hands an event to the matching subscribers without blocking. A
+// subscriber whose buffer is full is dropped.
+func (b *EventBus) Publish(event entity.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for subscriber := range b.subscribers {
+		if !subscriber.filter.matches(event) {
+			continue
+		}
+		select {
+		case subscriber.Events <- event:
+		default:
+			log.Println("Dropping event subscriber that does not keep up")
+			delete(b.subscribers, subscriber)
+			close(subscriber.Events)
+		}
+	}
+}
+
+// Close ends the streams of every subscriber and refuses new ones.
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for subscriber := range b.subscribers {
+		delete(b.subscribers, subscriber)
+		close(subscriber.Events)
+	}
+}
+
+func (f EventFilter) matches(event entity.Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, event.Type) {
+		return false
+	}
+	if f.Address != "" && !event.InvolvesAddress(f.Address) {
+		return false
+	}
+	if f.TransactionType != "" && (event.Transaction == nil || event.Transaction.Type != f.TransactionType) {
+		return false
+	}
+	return true
+}
+
+// getEventsHandler streams the events matching the filters of the query
+// string until the client disconnects or the node stops.
+func getEventsHandler(c *gin.Context) {
+	filter, err := parseEventFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscriber, ok := events.Subscribe(filter)
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "the node is stopping"})
+		return
+	}
+	defer events.Unsubscribe(subscriber)
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	// Clients learn that the stream is open before the first event
+	c.SSEvent("ready", gin.H{"time": time.Now()})
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-subscriber.Events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func parseEventFilter(c *gin.Context) (EventFilter, error) {
+	filter := EventFilter{
+		Address:         c.Query("address"),
+		TransactionType: c.Query("type"),
+	}
+
+	if eventTypes := c.Query("events"); eventTypes != "" {
+		for _, eventType := range strings.Split(eventTypes, ",") {
+			eventType = strings.TrimSpace(eventType)
+			if !entity.IsValidEventType(eventType) {
+				return filter, fmt.Errorf("invalid event %q", eventType)
+			}
+			filter.Types = append(filter.Types, eventType)
+		}
+	}
+	if filter.Address != "" && !entity.IsValidAddress(filter.Address) {
+		return filter, errors.New("invalid address")
+	}
+	if filter.TransactionType != "" && !entity.IsValidTransactionType(filter.TransactionType) && filter.TransactionType != entity.TransactionTypeOrigin {
+		return filter, fmt.Errorf("invalid type %q", filter.TransactionType)
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,278 @@
+package node
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"energy/domain/entity"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	transactionsBucket string = "transactions"
+	walletsBucket      string = "wallets"
+	pendingBucket      string = "pending"
+)
+
+const journalFileName = "ledger.journal"
+
+// FileStore is an embedded Store that appends every change to a journal file in
+// the data directory. The journal is replayed and compacted when it is opened.
+// A value that does not change is not appended again.
+type FileStore struct {
+	mu      sync.Mutex
+	file    *os.File
+	records map[string]map[string]json.RawMessage // Bucket -> key -> value
+}
+
+// storedWallet is the record of a wallet.
+type storedWallet struct {
+	Address string `json:"address"`
+}
+
+type journalRecord struct {
+	Bucket  string          `json:"bucket"`
+	Key     string          `json:"key"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Deleted bool            `json:"deleted,omitempty"`
+}
+
+func NewFileStore(dataDir string) (*FileStore, error) {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating data directory: %v", err)
+	}
+
+	path := filepath.Join(dataDir, journalFileName)
+
+	records, err := readJournal(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := compactJournal(path, records); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening journal: %v", err)
+	}
+
+	log.Printf("Opened ledger journal %s", path)
+
+	return &FileStore{
+		file:    file,
+		records: records,
+	}, nil
+}
+
+func readJournal(path string) (map[string]map[string]json.RawMessage, error) {
+	records := map[string]map[string]json.RawMessage{
+		transactionsBucket: {},
+		walletsBucket:      {},
+		pendingBucket:      {},
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening journal: %v", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				log.Println("Ignoring incomplete last journal record")
+			}
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading journal: %v", err)
+		}
+
+		var record journalRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("error decoding journal record: %v", err)
+		}
+
+		bucket, ok := records[record.Bucket]
+		if !ok {
+			return nil, fmt.Errorf("unknown journal bucket %q", record.Bucket)
+		}
+
+		if record.Deleted {
+			delete(bucket, record.Key)
+		} else {
+			bucket[record.Key] = record.Value
+		}
+	}
+}
+
+// compactJournal rewrites the journal with only the latest value of every key.
+func compactJournal(path string, records map[string]map[string]json.RawMessage) error {
+	temporaryPath := path + ".tmp"
+
+	file, err := os.Create(temporaryPath)
+	if err != nil {
+		return fmt.Errorf("error compacting journal: %v", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	for bucket, values := range records {
+		for key, value := range values {
+			line, err := json.Marshal(journalRecord{Bucket: bucket, Key: key, Value: value})
+			if err != nil {
+				file.Close()
+				return fmt.Errorf("error compacting journal: %v", err)
+			}
+			writer.Write(line)
+			writer.WriteByte('\n')
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("error compacting journal: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("error compacting journal: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error compacting journal: %v", err)
+	}
+
+	return os.Rename(temporaryPath, path)
+}
+
+func (s *FileStore) put(bucket string, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if previous, exists := s.records[bucket][key]; exists && bytes.Equal(previous, data) {
+		return nil
+	}
+	s.records[bucket][key] = data
+	return s.append(journalRecord{Bucket: bucket, Key: key, Value: data})
+}
+
+func (s *FileStore) delete(bucket string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records[bucket], key)
+	return s.append(journalRecord{Bucket: bucket, Key: key, Deleted: true})
+}
+
+func (s *FileStore) append(record journalRecord) error {
+	if s.file == nil {
+		return errors.New("store is closed")
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileStore) values(bucket string) []json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values := make([]json.RawMessage, 0, len(s.records[bucket]))
+	for _, value := range s.records[bucket] {
+		values = append(values, value)
+	}
+	return values
+}
+
+func (s *FileStore) SaveTransaction(transaction entity.Transaction) error {
+	return s.put(transactionsBucket, transaction.ID, transaction)
+}
+
+func (s *FileStore) LoadTransactions() ([]entity.Transaction, error) {
+	values := s.values(transactionsBucket)
+
+	transactions := make([]entity.Transaction, 0, len(values))
+	for _, value := range values {
+		var transaction entity.Transaction
+		if err := json.Unmarshal(value, &transaction); err != nil {
+			return nil, fmt.Errorf("error decoding stored transaction: %v", err)
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+func (s *FileStore) SaveWallet(wallet *entity.Wallet) error {
+	return s.put(walletsBucket, wallet.Address, storedWallet{Address: wallet.Address})
+}
+
+// LoadWallets returns the stored wallets, empty.
+func (s *FileStore) LoadWallets() ([]*entity.Wallet, error) {
+	values := s.values(walletsBucket)
+
+	wallets := make([]*entity.Wallet, 0, len(values))
+	for _, value := range values {
+		var stored storedWallet
+		if err := json.Unmarshal(value, &stored); err != nil {
+			return nil, fmt.Errorf("error decoding stored wallet: %v", err)
+		}
+		wallets = append(wallets, entity.NewWallet(stored.Address))
+	}
+	return wallets, nil
+}
+
+func (s *FileStore) AddPendingTransaction(id string) error {
+	return s.put(pendingBucket, id, true)
+}
+
+func (s *FileStore) DeletePendingTransaction(id string) error {
+	return s.delete(pendingBucket, id)
+}
+
+func (s *FileStore) LoadPendingTransactions() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.records[pendingBucket]))
+	for id := range s.records[pendingBucket] {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Close flushes the journal to disk and closes it.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	syncErr := s.file.Sync()
+	closeErr := s.file.Close()
+	s.file = nil
+
+	return errors.Join(syncErr, closeErr)
+}
@@ -0,0 +1,229 @@
+package node
+
+import (
+	"energy/domain/entity"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testAddressA = "100c8283eaefcbedcd4d330e83bda94f21687c35db5f5102743ca1215d153c3f"
+	testAddressB = "8501df062b55e6f938cf5c2c36849e8c11663f8f79e28bd1a99431d825792a44"
+)
+
+func openTestStore(t *testing.T, dataDir string) *FileStore {
+	t.Helper()
+	store, err := NewFileStore(dataDir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	return store
+}
+
+func closeTestStore(t *testing.T, store *FileStore) {
+	t.Helper()
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func journalLines(t *testing.T, dataDir string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dataDir, journalFileName))
+	if err != nil {
+		t.Fatalf("reading journal: %v", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestFileStoreReplaysJournal(t *testing.T) {
+	dataDir := t.TempDir()
+	store := openTestStore(t, dataDir)
+
+	first := entity.NewTransaction(testAddressA, testAddressB, 150000000, "first", entity.TransactionTypeStandard, 0, 0, 1, nil)
+	second := entity.NewTransaction(testAddressA, testAddressB, 250000000, "second", entity.TransactionTypeStandard, 0, 0, 2, []string{first.ID})
+	second.UpdateStatus(entity.TransactionStatusConfirmed)
+
+	wallet := entity.NewWallet(testAddressA)
+	wallet.Balance = 1000
+	wallet.Sequence = 2
+
+	for _, err := range []error{
+		store.SaveTransaction(first),
+		store.SaveTransaction(second),
+		store.SaveWallet(wallet),
+		store.AddPendingTransaction(first.ID),
+		store.AddPendingTransaction(second.ID),
+		store.DeletePendingTransaction(second.ID),
+	} {
+		if err != nil {
+			t.Fatalf("writing store: %v", err)
+		}
+	}
+	closeTestStore(t, store)
+
+	store = openTestStore(t, dataDir)
+	defer closeTestStore(t, store)
+
+	transactions, err := store.LoadTransactions()
+	if err != nil {
+		t.Fatalf("LoadTransactions: %v", err)
+	}
+	if len(transactions) != 2 {
+		t.Fatalf("got %d transactions, want 2", len(transactions))
+	}
+	for _, transaction := range transactions {
+		if transaction.ID == second.ID && transaction.Status != entity.TransactionStatusConfirmed {
+			t.Errorf("status of %s = %q, want confirmed", second.ID, transaction.Status)
+		}
+	}
+
+	wallets, err := store.LoadWallets()
+	if err != nil {
+		t.Fatalf("LoadWallets: %v", err)
+	}
+	if len(wallets) != 1 {
+		t.Fatalf("got %d wallets, want 1", len(wallets))
+	}
+	// Only the address is stored, the rest is derived from the transactions
+	restored := wallets[0]
+	if restored.Address != testAddressA || restored.Balance != 0 || restored.Sequence != 0 || len(restored.TransactionHistory) != 0 {
+		t.Errorf("restored wallet = %s %s %d %v, want an empty wallet of %s", restored.Address, restored.Balance, restored.Sequence, restored.TransactionHistory, testAddressA)
+	}
+
+	pending, err := store.LoadPendingTransactions()
+	if err != nil {
+		t.Fatalf("LoadPendingTransactions: %v", err)
+	}
+	if len(pending) != 1 || pending[0] != first.ID {
+		t.Errorf("pending = %v, want [%s]", pending, first.ID)
+	}
+}
+
+func TestFileStoreIgnoresIncompleteLastRecord(t *testing.T) {
+	dataDir := t.TempDir()
+	store := openTestStore(t, dataDir)
+
+	transaction := entity.NewTransaction(testAddressA, testAddressB, 100000000, "", entity.TransactionTypeStandard, 0, 0, 1, nil)
+	if err := store.SaveTransaction(transaction); err != nil {
+		t.Fatalf("SaveTransaction: %v", err)
+	}
+	closeTestStore(t, store)
+
+	// A crash in the middle of a write leaves a record without its newline
+	journal, err := os.OpenFile(filepath.Join(dataDir, journalFileName), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("opening journal: %v", err)
+	}
+	if _, err := journal.WriteString(`{"bucket":"transactions","key":"abc","val`); err != nil {
+		t.Fatalf("writing journal: %v", err)
+	}
+	journal.Close()
+
+	store = openTestStore(t, dataDir)
+	defer closeTestStore(t, store)
+
+	transactions, err := store.LoadTransactions()
+	if err != nil {
+		t.Fatalf("LoadTransactions: %v", err)
+	}
+	if len(transactions) != 1 || transactions[0].ID != transaction.ID {
+		t.Errorf("got %v, want only %s", transactions, transaction.ID)
+	}
+
+	// Compaction dropped the incomplete record, new records start on a line of their own
+	if err := store.AddPendingTransaction(transaction.ID); err != nil {
+		t.Fatalf("AddPendingTransaction: %v", err)
+	}
+	for _, line := range journalLines(t, dataDir) {
+		if strings.Contains(line, `"abc"`) {
+			t.Errorf("incomplete record survived: %s", line)
+		}
+	}
+}
+
+func TestFileStoreRejectsCorruptRecord(t *testing.T) {
+	dataDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dataDir, journalFileName), []byte("not json\n"), 0o644); err != nil {
+		t.Fatalf("writing journal: %v", err)
+	}
+
+	if _, err := NewFileStore(dataDir); err == nil {
+		t.Fatal("NewFileStore accepted a corrupt complete record")
+	}
+}
+
+func TestFileStoreCompactsJournal(t *testing.T) {
+	dataDir := t.TempDir()
+	store := openTestStore(t, dataDir)
+
+	if err := store.SaveWallet(entity.NewWallet(testAddressA)); err != nil {
+		t.Fatalf("SaveWallet: %v", err)
+	}
+	for i := 0; i < 25; i++ {
+		if err := store.AddPendingTransaction("pending"); err != nil {
+			t.Fatalf("AddPendingTransaction: %v", err)
+		}
+		if err := store.DeletePendingTransaction("pending"); err != nil {
+			t.Fatalf("DeletePendingTransaction: %v", err)
+		}
+	}
+	closeTestStore(t, store)
+
+	if lines := journalLines(t, dataDir); len(lines) != 51 {
+		t.Fatalf("journal has %d lines before compaction, want 51", len(lines))
+	}
+
+	store = openTestStore(t, dataDir)
+	defer closeTestStore(t, store)
+
+	lines := journalLines(t, dataDir)
+	if len(lines) != 1 {
+		t.Fatalf("journal has %d lines after compaction, want 1: %v", len(lines), lines)
+	}
+
+	wallets, err := store.LoadWallets()
+	if err != nil {
+		t.Fatalf("LoadWallets: %v", err)
+	}
+	if len(wallets) != 1 || wallets[0].Address != testAddressA {
+		t.Errorf("compaction lost the wallet: %v", wallets)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, journalFileName+".tmp")); !os.IsNotExist(err) {
+		t.Errorf("temporary compaction file left behind: %v", err)
+	}
+}
+
+func TestFileStoreSkipsUnchangedWallets(t *testing.T) {
+	dataDir := t.TempDir()
+	store := openTestStore(t, dataDir)
+
+	wallet := entity.NewWallet(testAddressA)
+	for i := uint64(1); i <= 20; i++ {
+		transaction := entity.NewTransaction(testAddressA, testAddressB, 1, "", entity.TransactionTypeStandard, 0, 0, i, nil)
+		wallet.Balance += entity.AmountUnit
+		wallet.Sequence = i
+		wallet.AddTransactionToHistory(&transaction)
+		if err := store.SaveWallet(wallet); err != nil {
+			t.Fatalf("SaveWallet: %v", err)
+		}
+	}
+	closeTestStore(t, store)
+
+	var walletRecords []string
+	for _, line := range journalLines(t, dataDir) {
+		if strings.Contains(line, `"bucket":"wallets"`) {
+			walletRecords = append(walletRecords, line)
+		}
+	}
+	if len(walletRecords) != 1 {
+		t.Fatalf("got %d wallet records, want 1: %v", len(walletRecords), walletRecords)
+	}
+	for _, field := range []string{"TransactionHistory", "alance", "equence", `"Mu"`} {
+		if strings.Contains(walletRecords[0], field) {
+			t.Errorf("wallet record holds %s: %s", field, walletRecords[0])
+		}
+	}
+}
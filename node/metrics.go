@@ -0,0 +1,111 @@
+package node
+
+import (
+	"energy/domain/entity"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The node exposes Prometheus metrics on /metrics of its API. They are
+// registered when the node starts, so that a process running only the wallet
+// does not report empty node metrics.
+
+// Sources of the transactions a node receives.
+const (
+	sourceHTTP   string = "http"
+	sourcePubsub string = "pubsub"
+	sourceSync   string = "sync"
+)
+
+// Reasons of the validation failures.
+const (
+	reasonMalformed     string = "malformed"
+	reasonDuplicate     string = "duplicate"
+	reasonID            string = "id"
+	reasonAddress       string = "address"
+	reasonType          string = "type"
+	reasonSignature     string = "signature"
+	reasonSequence      string = "sequence"
+	reasonParents       string = "parents"
+	reasonSelectionTips string = "selection-tips"
+	reasonAmount        string = "amount"
+	reasonFee           string = "fee"
+	reasonBalance       string = "balance"
+	reasonPow           string = "pow"
+	reasonOther         string = "other"
+)
+
+var (
+	transactionsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "energy_node_transactions_received_total",
+		Help: "Transactions received by the node, by source.",
+	}, []string{"source"})
+
+	transactionsAccepted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "energy_node_transactions_accepted_total",
+		Help: "Transactions added to the DAG, by source.",
+	}, []string{"source"})
+
+	validationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "energy_node_transaction_validation_failures_total",
+		Help: "Transactions refused by the node, by source and reason.",
+	}, []string{"source", "reason"})
+
+	confirmationLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "energy_node_confirmation_latency_seconds",
+		Help:    "Time from the creation to the confirmation of the transactions confirmed by the node.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 16),
+	})
+)
+
+func initMetrics() {
+	prometheus.MustRegister(
+		transactionsReceived,
+		transactionsAccepted,
+		validationFailures,
+		confirmationLatency,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "energy_node_pending_transactions",
+			Help: "Transactions waiting for confirmation in the pending pool.",
+		}, func() float64 { return float64(pendingTransactions.Count()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "energy_node_dag_transactions",
+			Help: "Transactions in the DAG.",
+		}, func() float64 { return float64(dag.countTransactions()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "energy_node_dag_tips",
+			Help: "Transactions of the DAG that no transaction approves yet.",
+		}, func() float64 { return float64(dag.countTips()) }),
+	)
+}
+
+// validationError labels a validation failure with its reason.
+type validationError struct {
+	reason string
+	err    error
+}
+
+func (e *validationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *validationError) Unwrap() error {
+	return e.err
+}
+
+func invalid(reason string, err error) error {
+	return &validationError{reason: reason, err: err}
+}
+
+func recordValidationFailure(source string, err error) {
+	reason := reasonOther
+	var failure *validationError
+	if errors.As(err, &failure) {
+		reason = failure.reason
+	}
+	validationFailures.WithLabelValues(source, reason).Inc()
+}
+
+func recordConfirmation(transaction entity.Transaction) {
+	confirmationLatency.Observe(transaction.TimestampConfirmed.Sub(transaction.TimestampCreated).Seconds())
+}
@@ -1,24 +1,71 @@
 package node
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"log"
+	"net"
+	"net/http"
 )
 
-func initNodeAPI(port string) {
+var nodeServer *http.Server
+
+func initNodeAPI(port string) error {
 	log.Println("Starting node api...")
-	go func() {
 
-		router := gin.Default()
+	router := gin.Default()
+
+	// Client addresses key the PoW service quota, only trusted proxies may
+	// report them
+	if err := router.SetTrustedProxies(nodeConfig.TrustedProxies); err != nil {
+		return err
+	}
+
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	router.GET("/node/health", getHealthHandler)
+
+	router.GET("/node/peers", getPeersHandler)
+
+	router.GET("/node/dag", getDagHandler)
+
+	router.GET("/node/events", getEventsHandler)
+
+	router.POST("/node/newWallet/:address", postNewWalletHandler)
 
-		router.GET("/node/dag", getDagHandler)
+	router.GET("/node/wallet/:address", getWalletHandler)
 
-		router.POST("/node/newWallet/:seed", postNewWalletHandler)
+	router.GET("/node/wallet/:address/history", getWalletHistoryHandler)
 
-		router.GET("/node/selectionTips", getSelectionTipsHandler)
+	router.GET("/node/difficulty", getDifficultyHandler)
 
-		router.POST("/node/transaction", postNewTransactionHandler)
+	router.GET("/node/selectionTips", getSelectionTipsHandler)
 
-		router.Run(":" + port)
+	router.POST("/node/transaction", postNewTransactionHandler)
+
+	router.GET("/node/transaction/:id", getTransactionHandler)
+
+	router.GET("/node/transactions", getTransactionsHandler)
+
+	router.POST("/node/transactions/lookup", postTransactionLookupHandler)
+
+	if nodeConfig.PowService {
+		router.POST("/node/pow", postPowHandler)
+	}
+
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+
+	nodeServer = &http.Server{Handler: router}
+
+	go func() {
+		if err := nodeServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Println("Error serving node api:", err)
+		}
 	}()
+
+	return nil
 }
@@ -2,27 +2,37 @@ package node
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
+	"energy/config"
 	"energy/domain/entity"
 	"energy/domain/entity/request"
+	"energy/pow"
 	"energy/pubsub"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"log"
-	"math"
 	"net/http"
-	"strings"
+	"slices"
+	"strconv"
+	"time"
 )
 
-var walletStore = NewWalletStore()
+// genesis defines the initial allocations shared by every node of the network
+var genesis entity.Genesis
+
+// genesisHash identifies the network, peers with another one are refused
+var genesisHash string
+
+var walletStore = &WalletStore{Wallets: make(map[string]*entity.Wallet)}
 
 var pendingTransactions = NewPendingTransactions()
 
 var pubsubOutput pubsub.PubsubOutputInterface
 
+// maxParents is the maximum number of transactions a new transaction can approve.
+const maxParents = 2
+
 type Node struct {
 	PubsubInput pubsub.PubsubInputInterface
 }
@@ -33,29 +43,123 @@ func NewNodeDI(pubsubInput pubsub.PubsubInputInterface) *Node {
 	}
 }
 
-func StartNode(pubsubOutputImpl pubsub.PubsubOutputImpl, port string) {
+var nodeConfig config.NodeConfig
+
+// nodeCtx lives as long as the node, background work of the node uses it
+var nodeCtx = context.Background()
+
+// StartNode restores or initializes the ledger and starts the node API. The
+// background work of the node stops when the context is done.
+func StartNode(ctx context.Context, pubsubOutputImpl pubsub.PubsubOutputImpl, nodeCfg config.NodeConfig, genesisDefinition entity.Genesis) error {
 	log.Println("Initializing node...")
 
+	nodeCtx = ctx
 	pubsubOutput = pubsubOutputImpl
 
-	initDAG()
-	addPendingTransactionsToDAG()
-	initNodeAPI(port)
+	if nodeCfg.ConfirmationWeight < 1 {
+		return errors.New("confirmation weight must be at least 1")
+	}
+	if nodeCfg.ConfirmationConfidence > 0 && nodeCfg.ConfirmationSamples < 1 {
+		return errors.New("confirmation samples must be at least 1")
+	}
+	if nodeCfg.PowMaxAdjustment < 0 {
+		return errors.New("proof of work max adjustment cannot be negative")
+	}
+	if err := initPowService(nodeCfg); err != nil {
+		return err
+	}
+	nodeConfig = nodeCfg
+
+	if err := initGenesis(genesisDefinition); err != nil {
+		return err
+	}
+
+	initMetrics()
+
+	var err error
+	tipSelector, err = NewTipSelector(nodeCfg.TipSelection, nodeCfg.TipSelectionAlpha)
+	if err != nil {
+		return err
+	}
+	log.Printf("Using %s tip selection", nodeCfg.TipSelection)
+
+	store, err = OpenStore(nodeCfg.DataDir)
+	if err != nil {
+		return err
+	}
+
+	restored, err := restoreLedger()
+	if err != nil {
+		return err
+	}
+	if !restored {
+		initDAG()
+		walletStore.PersistWallets()
+	}
+
+	return initNodeAPI(nodeCfg.Port)
 }
 
-func NewWalletStore() *WalletStore {
+// StopNode stops accepting API requests, waits for the ones in flight until
+// the context is done and closes the store.
+func StopNode(ctx context.Context) error {
+	log.Println("Stopping node...")
+
+	var errs []error
+	if nodeServer != nil {
+		// Event streams never end by themselves
+		events.Close()
+		errs = append(errs, nodeServer.Shutdown(ctx))
+	}
+
+	// No ledger change can be half written once the mutex is held
+	ledgerMu.Lock()
+	defer ledgerMu.Unlock()
+
+	errs = append(errs, store.Close())
+
+	return errors.Join(errs...)
+}
+
+// NewWalletStore returns a store holding the wallets credited by the origin
+// transactions, which start their history. They are not persisted, they are
+// either restored or persisted with the rest of a new ledger.
+func NewWalletStore(originTransactions []entity.Transaction) *WalletStore {
 	walletStore := &WalletStore{
 		Wallets: make(map[string]*entity.Wallet),
 	}
 
-	// Origin wallet
-	originWallet := entity.NewWallet("9a27ad25d050b690b05d38e1cf20c71e8c5314cff5a936efc024a2b5e9b07f04")
-	originWallet.IncreaseBalance(1000000000)
-	walletStore.SaveWallet(originWallet)
+	for _, transaction := range originTransactions {
+		if transaction.To == "" {
+			continue
+		}
+		wallet := entity.NewWallet(transaction.To)
+		wallet.Balance = transaction.Token
+		wallet.TransactionHistory = []entity.Transaction{transaction}
+		walletStore.Wallets[transaction.To] = wallet
+	}
 
 	return walletStore
 }
 
+// initGenesis derives the origin transactions and the initial wallets from
+// the genesis.
+func initGenesis(genesisDefinition entity.Genesis) error {
+	if err := genesisDefinition.Validate(); err != nil {
+		return err
+	}
+
+	genesis = genesisDefinition
+	genesisHash = genesis.Hash()
+	originTransactions = newOriginTransactions()
+	originTransaction = originTransactions[0]
+	walletStore = NewWalletStore(originTransactions)
+
+	log.Printf("Genesis %s allocates tokens to %d addresses", genesisHash, len(genesis.Allocations))
+
+	return nil
+}
+
 func NewPendingTransactions() *entity.PendingTransactions {
 	pendingTransactions := &entity.PendingTransactions{
 		Transactions: make(map[string]entity.Transaction),
@@ -64,13 +168,76 @@ func NewPendingTransactions() *entity.PendingTransactions {
 	return pendingTransactions
 }
 
-func addPendingTransactionsToDAG() {
-	// Fake method to add pending transactions to the DAG
-	pendingTransactions.InitPendingTransactions()
+func addPendingTransaction(transaction entity.Transaction) {
+	pendingTransactions.AddTransaction(transaction)
+	inflowMonitor.RecordArrival()
+	if err := store.AddPendingTransaction(transaction.ID); err != nil {
+		log.Printf("Error persisting pending transaction %s: %v", transaction.ID, err)
+	}
+}
+
+func deletePendingTransaction(id string) {
+	pendingTransactions.DeleteTransaction(id)
+	if err := store.DeletePendingTransaction(id); err != nil {
+		log.Printf("Error deleting pending transaction %s: %v", id, err)
+	}
+}
+
+// restoreLedger reloads the DAG, the wallets and the pending pool from the
+// store, deriving the balances from the confirmed transactions. It returns
+// false when the store is empty.
+func restoreLedger() (bool, error) {
+	transactions, err := store.LoadTransactions()
+	if err != nil {
+		return false, err
+	}
+	if len(transactions) == 0 {
+		return false, nil
+	}
+
+	wallets, err := store.LoadWallets()
+	if err != nil {
+		return false, err
+	}
+
+	pendingIDs, err := store.LoadPendingTransactions()
+	if err != nil {
+		return false, err
+	}
+
+	dag = newDAG()
+	for _, transaction := range sortTopologically(transactions) {
+		dag.restoreTransaction(transaction)
+	}
+	if !dag.hasTransaction(originTransaction.ID) {
+		return false, errors.New("the stored ledger belongs to another genesis")
+	}
+
+	if err := walletStore.RestoreWallets(wallets, transactions); err != nil {
+		return false, err
+	}
 
-	for _, transaction := range pendingTransactions.Transactions {
-		dag.addTransaction(transaction)
+	// The pending pool record of a transaction is deleted after its status is
+	// saved, a crash in between leaves the record of a decided transaction
+	for _, id := range pendingIDs {
+		transaction, exists := dag.getTransactionByID(id)
+		if !exists {
+			log.Printf("Ignoring unknown pending transaction %s", id)
+			continue
+		}
+		if transaction.Status != entity.TransactionStatusPending && transaction.Status != entity.TransactionStatusConflicting {
+			deletePendingTransaction(id)
+			continue
+		}
+		pendingTransactions.AddTransaction(transaction)
+		if err := pendingSpends.AddSpend(&transaction); err != nil {
+			log.Printf("Error restoring spend of transaction %s: %v", id, err)
+		}
 	}
+
+	log.Printf("Restored %d transactions, %d wallets and %d pending transactions", len(transactions), len(wallets), len(pendingIDs))
+
+	return true, nil
 }
 
 func sendWalletCreatePubSubMessage(ctx context.Context, a_wallet *entity.Wallet) {
@@ -85,32 +252,151 @@ func sendWalletCreatePubSubMessage(ctx context.Context, a_wallet *entity.Wallet)
 	})
 }
 
+func publishWalletCreated(address string) {
+	events.Publish(entity.Event{Type: entity.EventWalletCreated, Time: time.Now(), Address: address})
+}
+
 func getDagHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, dag)
+	c.JSON(http.StatusOK, gin.H{
+		"Transactions": dag.getTransactions(),
+	})
 }
 
 func postNewWalletHandler(c *gin.Context) {
 
-	walletSeed := c.Param("seed")
+	walletAddress := c.Param("address")
+
+	if !entity.IsValidAddress(walletAddress) {
+		log.Println("Invalid wallet address")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet address"})
+		return
+	}
+
+	newWallet, exists := walletStore.GetWallet(walletAddress)
+	if !exists {
+		newWallet = entity.NewWallet(walletAddress)
+		walletStore.SaveWallet(newWallet)
+		publishWalletCreated(walletAddress)
+
+		sendWalletCreatePubSubMessage(c, newWallet)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"address": walletAddress,
+	})
+}
+
+func getWalletHandler(c *gin.Context) {
+	address := c.Param("address")
+
+	if !entity.IsValidAddress(address) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet address"})
+		return
+	}
+
+	wallet, exists := walletStore.GetWallet(address)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		return
+	}
+
+	pendingOutgoing, err := pendingSpends.GetPendingOutgoing(address)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"address":         wallet.Address,
+		"balance":         wallet.GetBalance(),
+		"pendingOutgoing": pendingOutgoing,
+		"sequence":        wallet.GetSequence(),
+	})
+}
+
+// getWalletHistoryHandler returns a page of the confirmed transactions sent
+// or received by a wallet, newest first unless order is asc. The cursor is the
+// ID of the last transaction of the previous page, so that it also works on
+// the other nodes, whose histories can be in another order.
+func getWalletHistoryHandler(c *gin.Context) {
+	address := c.Param("address")
+
+	if !entity.IsValidAddress(address) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet address"})
+		return
+	}
+
+	direction := c.Query("direction")
+	if direction != "" && direction != "sent" && direction != "received" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid direction, expected sent or received"})
+		return
+	}
+
+	descending := true
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+		descending = false
+	case "desc":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order, expected asc or desc"})
+		return
+	}
+
+	limit := defaultQueryLimit
+	if limitParam := c.Query("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 || parsed > maxQueryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxQueryLimit)})
+			return
+		}
+		limit = parsed
+	}
 
-	if walletSeed == "" {
-		log.Println("Invalid wallet seed")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet seed"})
+	wallet, exists := walletStore.GetWallet(address)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
 		return
 	}
+	history := wallet.GetTransactionHistory()
 
-	newWallet := entity.NewWallet(walletSeed)
-	walletStore.SaveWallet(newWallet)
+	position, step := 0, 1
+	if descending {
+		position, step = len(history)-1, -1
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		cursorPosition := slices.IndexFunc(history, func(transaction entity.Transaction) bool { return transaction.ID == cursor })
+		if cursorPosition < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		position = cursorPosition + step
+	}
 
-	sendWalletCreatePubSubMessage(c, newWallet)
+	transactions := make([]entity.Transaction, 0, limit)
+	for ; position >= 0 && position < len(history) && len(transactions) < limit; position += step {
+		transaction := history[position]
+		if (direction == "sent" && transaction.From != address) || (direction == "received" && transaction.To != address) {
+			continue
+		}
+		transactions = append(transactions, transaction)
+	}
 
+	page := gin.H{"transactions": transactions}
+	if len(transactions) > 0 && position >= 0 && position < len(history) {
+		page["nextCursor"] = transactions[len(transactions)-1].ID
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func getDifficultyHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"seed": walletSeed,
+		"adjustment": advertiseDifficultyAdjustment(),
+		"inflow":     inflowMonitor.GetInflow(),
 	})
 }
 
 func getSelectionTipsHandler(c *gin.Context) {
-	transactions := pendingTransactions.GetOldestTransactions()
+	transactions := tipSelector.SelectTips()
 
 	c.JSON(http.StatusOK, gin.H{
 		"transactions": transactions,
@@ -120,26 +406,50 @@ func getSelectionTipsHandler(c *gin.Context) {
 func postNewTransactionHandler(c *gin.Context) {
 	var newTransactionRequest request.NewTransactionNodeRequest
 
+	transactionsReceived.WithLabelValues(sourceHTTP).Inc()
+
 	if err := c.ShouldBindJSON(&newTransactionRequest); err != nil {
+		recordValidationFailure(sourceHTTP, invalid(reasonMalformed, err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := validateTransaction(&newTransactionRequest.Transaction); err != nil {
+	if dag.hasTransaction(newTransactionRequest.Transaction.ID) {
+		recordValidationFailure(sourceHTTP, invalid(reasonDuplicate, errors.New("transaction already exists")))
+		c.JSON(http.StatusConflict, gin.H{"error": "transaction already exists", "id": newTransactionRequest.Transaction.ID})
+		return
+	}
+
+	// A wallet failing over from another node submits tips of that node, which
+	// may not have reached this one yet
+	if entity.HasValidID(&newTransactionRequest.Transaction) && entity.IsValidSignature(&newTransactionRequest.Transaction) {
+		if err := syncMissingParents(c.Request.Context(), &newTransactionRequest.Transaction); err != nil {
+			log.Println("Error syncing missing parents:", err)
+		}
+	}
+
+	if err := validateTransaction(&newTransactionRequest.Transaction, acceptedDifficultyAdjustment()); err != nil {
+		recordValidationFailure(sourceHTTP, err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := validateSelectionTips(&newTransactionRequest.SelectionTips); err != nil {
+	if err := validateSelectionTips(&newTransactionRequest.Transaction, &newTransactionRequest.SelectionTips); err != nil {
+		recordValidationFailure(sourceHTTP, err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	newTransactionRequest.Transaction.UpdateTimestampAdded()
 
-	pendingTransactions.AddTransaction(newTransactionRequest.Transaction)
-
-	dag.addTransaction(newTransactionRequest.Transaction)
+	acceptedTransaction, err := acceptTransaction(newTransactionRequest.Transaction)
+	if err != nil {
+		recordValidationFailure(sourceHTTP, err)
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	newTransactionRequest.Transaction = acceptedTransaction
+	transactionsAccepted.WithLabelValues(sourceHTTP).Inc()
 
 	newTransactionNodeRequest := request.NewTransactionNodeRequest{Transaction: newTransactionRequest.Transaction, SelectionTips: newTransactionRequest.SelectionTips}
 
@@ -162,50 +472,78 @@ func sendNewTransactionPubSubMessage(ctx context.Context, newTransactionNodeRequ
 	})
 }
 
-func validateTransaction(newTransaction *entity.Transaction) error {
+// validateTransaction checks a new transaction before it is accepted. The proof
+// of work of standard transactions must meet the difficulty raised by the given
+// adjustment.
+func validateTransaction(newTransaction *entity.Transaction, difficultyAdjustment int) error {
 	if !entity.IsValidID(newTransaction.ID) {
-		return errors.New("invalid transaction id")
+		return invalid(reasonID, errors.New("invalid transaction id"))
+	}
+
+	if !entity.HasValidID(newTransaction) {
+		return invalid(reasonID, errors.New("transaction id does not match its contents"))
 	}
 
 	if !entity.IsValidAddress(newTransaction.From) {
-		return errors.New("invalid transaction FROM address")
+		return invalid(reasonAddress, errors.New("invalid transaction FROM address"))
 	}
 
 	if !entity.IsValidAddress(newTransaction.To) {
-		return errors.New("invalid transaction TO address")
+		return invalid(reasonAddress, errors.New("invalid transaction TO address"))
 	}
 
 	if !entity.IsValidTransactionType(newTransaction.Type) {
-		return errors.New("invalid transaction type")
+		return invalid(reasonType, errors.New("invalid transaction type"))
+	}
+
+	if !entity.IsSigned(newTransaction) {
+		return invalid(reasonSignature, errors.New("unsigned transaction"))
+	}
+
+	if !entity.IsValidSignature(newTransaction) {
+		return invalid(reasonSignature, errors.New("invalid transaction signature"))
+	}
+
+	if err := validateSequence(newTransaction); err != nil {
+		return invalid(reasonSequence, err)
+	}
+
+	if err := validateParents(newTransaction); err != nil {
+		return invalid(reasonParents, err)
+	}
+
+	totalAmount, err := newTransaction.TotalAmount()
+	if err != nil {
+		return invalid(reasonAmount, err)
 	}
 
 	if newTransaction.Type == entity.TransactionTypeFast {
 		if !entity.IsValidFee(newTransaction.Fee, newTransaction.Data) {
-			return errors.New("invalid transaction fee")
+			return invalid(reasonFee, errors.New("invalid transaction fee"))
 		}
 		fromWallet, exists := walletStore.GetWallet(newTransaction.From)
 		if !exists {
-			return errors.New("not enough tokens to perform transaction")
+			return invalid(reasonBalance, errors.New("not enough tokens to perform transaction"))
 		}
 
-		if newTransaction.Fee+newTransaction.Token > fromWallet.Balance {
-			return errors.New("not enough tokens to perform transaction")
+		if totalAmount > fromWallet.GetBalance() {
+			return invalid(reasonBalance, errors.New("not enough tokens to perform transaction"))
 		}
 	}
 
 	if newTransaction.Type == entity.TransactionTypeStandard {
-		if !verifyProofOfWork(newTransaction.Data, newTransaction.Nonce) {
-			return errors.New("invalid PoW")
+		if !verifyProofOfWork(newTransaction, difficultyAdjustment) {
+			return invalid(reasonPow, errors.New("invalid PoW"))
 		}
 
 		if newTransaction.Token > 0 {
 			fromWallet, exists := walletStore.GetWallet(newTransaction.From)
 			if !exists {
-				return errors.New("not enough tokens to perform transaction")
+				return invalid(reasonBalance, errors.New("not enough tokens to perform transaction"))
 			}
 
-			if newTransaction.Token > fromWallet.Balance {
-				return errors.New("not enough tokens to perform transaction")
+			if totalAmount > fromWallet.GetBalance() {
+				return invalid(reasonBalance, errors.New("not enough tokens to perform transaction"))
 			}
 		}
 
@@ -213,50 +551,93 @@ func validateTransaction(newTransaction *entity.Transaction) error {
 	return nil
 }
 
-func validateSelectionTips(selectionTips *[]entity.Transaction) error {
-	for _, selectionTip := range *selectionTips {
+// validateSequence rejects transactions reusing the sequence of a confirmed
+// transaction of their sender. Gossip neither orders nor guarantees the
+// delivery of transactions, so a transaction may skip sequences that arrive
+// later or never. A transaction reusing the sequence of a pending one is a
+// double spend, left to the conflict resolution.
+func validateSequence(newTransaction *entity.Transaction) error {
+	if newTransaction.Sequence == 0 {
+		return errors.New("transaction sequence must start at 1")
+	}
+	if dag.hasConfirmedSequence(newTransaction.From, newTransaction.Sequence) {
+		return fmt.Errorf("transaction sequence %d was already used", newTransaction.Sequence)
+	}
+	return nil
+}
+
+func validateParents(newTransaction *entity.Transaction) error {
+	if len(newTransaction.Parents) == 0 || len(newTransaction.Parents) > maxParents {
+		return fmt.Errorf("a transaction must approve between 1 and %d parents", maxParents)
+	}
 
-		if err := validateTransaction(&selectionTip); err != nil {
-			return errors.New("invalid selection tip. error: " + err.Error())
+	seen := make(map[string]bool, len(newTransaction.Parents))
+	for _, parent := range newTransaction.Parents {
+		if seen[parent] {
+			return errors.New("duplicated transaction parent " + parent)
 		}
+		seen[parent] = true
 
-		if err := dag.ConfirmTransaction(selectionTip.ID); err != nil {
-			return errors.New("error confirming transaction")
+		parentTransaction, exists := dag.getTransactionByID(parent)
+		if !exists {
+			return errors.New("unknown transaction parent " + parent)
 		}
 
-		tokenInterchangeErr := performTokenInterchange(selectionTip.From, selectionTip.To, selectionTip.Token, selectionTip.Fee)
-		if tokenInterchangeErr != nil {
-			return tokenInterchangeErr
+		if parentTransaction.Status == entity.TransactionStatusRejected {
+			return errors.New("transaction approves rejected parent " + parent)
 		}
+	}
+
+	return nil
+}
 
-		pendingTransactions.DeleteTransaction(selectionTip.ID)
+func validateSelectionTips(newTransaction *entity.Transaction, selectionTips *[]entity.Transaction) error {
+	if len(*selectionTips) != len(newTransaction.Parents) {
+		return invalid(reasonSelectionTips, errors.New("selection tips do not match transaction parents"))
+	}
 
-		//Add transaction to wallet history
-		selectionTipWallet, exists := walletStore.GetWallet(selectionTip.From)
-		if !exists {
-			return errors.New("from wallet not found")
+	for _, selectionTip := range *selectionTips {
+		if !slices.Contains(newTransaction.Parents, selectionTip.ID) {
+			return invalid(reasonSelectionTips, errors.New("selection tip "+selectionTip.ID+" is not a transaction parent"))
 		}
-		selectionTipWallet.AddTransactionToHistory(&selectionTip)
 	}
 
 	return nil
 }
 
-func verifyProofOfWork(transactionData string, nonce uint64) bool {
-	targetPrefixLength := int(math.Max(1, math.Log2(float64(len(transactionData)/10)))) + 1
-	targetPrefix := strings.Repeat("0", targetPrefixLength)
+// acceptTransaction adds a validated transaction to the DAG as pending, or as
+// conflicting when it conflicts with the pending transactions of its sender,
+// and confirms the transactions whose confirmation threshold it completes. The
+// sequence is checked again under the ledger mutex, a transaction with the same
+// sequence may have been confirmed meanwhile.
+func acceptTransaction(transaction entity.Transaction) (entity.Transaction, error) {
+	ledgerMu.Lock()
+	defer ledgerMu.Unlock()
+
+	if err := validateSequence(&transaction); err != nil {
+		return transaction, invalid(reasonSequence, err)
+	}
+	walletStore.RaiseSequence(transaction.From, transaction.Sequence)
+
+	// The status is decided by this node, never by the sender
+	transaction.UpdateStatus(entity.TransactionStatusPending)
+	transaction.TimestampConfirmed = time.Time{}
+
+	detectConflict(&transaction)
+
+	addPendingTransaction(transaction)
 
-	// Combine transaction data with nonce
-	attempt := fmt.Sprintf("%s%d", transactionData, nonce)
+	dag.addTransaction(transaction)
 
-	// Calculate hash
-	hash := sha256.New()
-	hash.Write([]byte(attempt))
-	hashBytes := hash.Sum(nil)
-	hashString := hex.EncodeToString(hashBytes)
+	confirmTransactions()
 
-	// Check if the hash meets the PoW criteria
-	return hashString[:len(targetPrefix)] == targetPrefix
+	return transaction, nil
+}
+
+// verifyProofOfWork checks the nonce against the whole transaction, so that it
+// cannot be reused for another transaction with the same data.
+func verifyProofOfWork(transaction *entity.Transaction, difficultyAdjustment int) bool {
+	return pow.Verify(transaction.PowPreimage(), transaction.Nonce, pow.Difficulty(len(transaction.Data), difficultyAdjustment))
 }
 
 type PubsubInputImpl struct {
@@ -265,16 +646,24 @@ type PubsubInputImpl struct {
 func (e PubsubInputImpl) WalletCreateMessage(message entity.PubsubMessage) {
 	fmt.Printf("Node received message: %s\n", message.Data)
 
-	var newWallet *entity.Wallet
+	newWallet := &entity.Wallet{}
 	err := json.Unmarshal([]byte(message.Data), newWallet)
 	if err != nil {
 		fmt.Println("Error unmarshaling JSON:", err)
 		return
 	}
 
-	_, exists := walletStore.GetWallet(newWallet.Seed)
+	if !entity.IsValidAddress(newWallet.Address) {
+		fmt.Println("Invalid wallet address:", newWallet.Address)
+		return
+	}
+
+	// Only the address is taken from the peer, balances and sequences are
+	// always derived from confirmed transactions
+	_, exists := walletStore.GetWallet(newWallet.Address)
 	if !exists {
-		walletStore.SaveWallet(newWallet)
+		walletStore.SaveWallet(entity.NewWallet(newWallet.Address))
+		publishWalletCreated(newWallet.Address)
 	}
 }
 
@@ -282,30 +671,55 @@ func (e PubsubInputImpl) NewTransactionMessage(message entity.PubsubMessage) {
 	fmt.Printf("Node received message: %s\n", message.Data)
 
 	var newTransactionNodeRequest request.NewTransactionNodeRequest
+
+	transactionsReceived.WithLabelValues(sourcePubsub).Inc()
+
 	err := json.Unmarshal([]byte(message.Data), &newTransactionNodeRequest)
 	if err != nil {
+		recordValidationFailure(sourcePubsub, invalid(reasonMalformed, err))
 		fmt.Println("Error unmarshaling JSON:", err)
 		return
 	}
 
-	if err := validateTransaction(&newTransactionNodeRequest.Transaction); err != nil {
+	if err := syncMissingParents(nodeCtx, &newTransactionNodeRequest.Transaction); err != nil {
+		fmt.Println("Error syncing missing parents:", err)
+		return
+	}
+
+	if dag.hasTransaction(newTransactionNodeRequest.Transaction.ID) {
+		recordValidationFailure(sourcePubsub, invalid(reasonDuplicate, errors.New("transaction already exists")))
+		fmt.Println("Transaction already exists:", newTransactionNodeRequest.Transaction.ID)
+		return
+	}
+
+	if err := validateTransaction(&newTransactionNodeRequest.Transaction, baseDifficultyAdjustment()); err != nil {
+		recordValidationFailure(sourcePubsub, err)
 		fmt.Println("Error validating transaction:", err)
 		return
 	}
 
-	if err := validateSelectionTips(&newTransactionNodeRequest.SelectionTips); err != nil {
+	if err := validateSelectionTips(&newTransactionNodeRequest.Transaction, &newTransactionNodeRequest.SelectionTips); err != nil {
+		recordValidationFailure(sourcePubsub, err)
 		fmt.Println("Error validating selection tips:", err)
 		return
 	}
 
-	pendingTransactions.AddTransaction(newTransactionNodeRequest.Transaction)
-
-	dag.addTransaction(newTransactionNodeRequest.Transaction)
+	if _, err := acceptTransaction(newTransactionNodeRequest.Transaction); err != nil {
+		recordValidationFailure(sourcePubsub, err)
+		fmt.Println("Error accepting transaction:", err)
+		return
+	}
+	transactionsAccepted.WithLabelValues(sourcePubsub).Inc()
 
 	fmt.Printf("Node added transaction: %s\n", newTransactionNodeRequest.Transaction.ID)
 }
 
-func performTokenInterchange(from string, to string, token float64, fee float64) error {
+func performTokenInterchange(from string, to string, token entity.Amount, fee entity.Amount) error {
+	total, err := token.Add(fee)
+	if err != nil {
+		return err
+	}
+
 	fromWallet, exists := walletStore.GetWallet(from)
 	if !exists {
 		return errors.New("from wallet not found performing token interchange")
@@ -315,16 +729,17 @@ func performTokenInterchange(from string, to string, token float64, fee float64)
 	if !exists {
 		toWallet = entity.NewWallet(to)
 		walletStore.SaveWallet(toWallet)
+		publishWalletCreated(to)
 	}
 
-	errDecreasingBalance := walletStore.DecreaseBalance(fromWallet.Seed, token+fee)
+	errDecreasingBalance := walletStore.DecreaseBalance(fromWallet.Address, total)
 	if errDecreasingBalance != nil {
 		return errDecreasingBalance
 	}
-	errIncreasingBalance := walletStore.IncreaseBalance(toWallet.Seed, token)
+	errIncreasingBalance := walletStore.IncreaseBalance(toWallet.Address, token)
 	if errIncreasingBalance != nil {
 		// If increasing balance fails, restore the balance of the sender
-		restatingBalanceErr := walletStore.IncreaseBalance(fromWallet.Seed, token+fee)
+		restatingBalanceErr := walletStore.IncreaseBalance(fromWallet.Address, total)
 		if restatingBalanceErr != nil {
 			return restatingBalanceErr
 		}
@@ -333,3 +748,17 @@ func performTokenInterchange(from string, to string, token float64, fee float64)
 
 	return nil
 }
+
+// revertTokenInterchange undoes a token interchange.
+func revertTokenInterchange(from string, to string, token entity.Amount, fee entity.Amount) {
+	total, err := token.Add(fee)
+	if err == nil {
+		err = walletStore.DecreaseBalance(to, token)
+	}
+	if err == nil {
+		err = walletStore.IncreaseBalance(from, total)
+	}
+	if err != nil {
+		log.Printf("Error reverting the token interchange from %s to %s: %v", from, to, err)
+	}
+}
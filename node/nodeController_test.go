@@ -0,0 +1,107 @@
+package node
+
+import (
+	"energy/domain/entity"
+	"testing"
+)
+
+func TestAcceptTransactionToleratesSequenceGapsAndRejectsReplays(t *testing.T) {
+	newTestLedger(t, 2)
+	root := originTransaction.ID
+
+	first := entity.NewTransaction(testAddressA, testAddressB, entity.AmountUnit, "", entity.TransactionTypeStandard, 0, 0, 1, []string{root})
+	second := entity.NewTransaction(testAddressA, testAddressB, entity.AmountUnit, "", entity.TransactionTypeStandard, 0, 0, 2, []string{root})
+
+	// Gossip delivered the second transaction before the first one
+	for _, transaction := range []entity.Transaction{second, first} {
+		if _, err := acceptTransaction(transaction); err != nil {
+			t.Fatalf("acceptTransaction(sequence %d): %v", transaction.Sequence, err)
+		}
+	}
+	wallet, _ := walletStore.GetWallet(testAddressA)
+	if wallet.GetSequence() != 2 {
+		t.Errorf("sequence of A = %d, want 2", wallet.GetSequence())
+	}
+
+	approver := entity.NewTransaction(testAddressB, testAddressA, 0, "", entity.TransactionTypeStandard, 0, 0, 1, []string{first.ID, second.ID})
+	if _, err := acceptTransaction(approver); err != nil {
+		t.Fatalf("acceptTransaction(approver): %v", err)
+	}
+	if got := ledgerStatuses([]string{first.ID, second.ID}); got[first.ID] != entity.TransactionStatusConfirmed || got[second.ID] != entity.TransactionStatusConfirmed {
+		t.Fatalf("statuses = %v, want both confirmed", got)
+	}
+
+	replay := entity.NewTransaction(testAddressA, testAddressB, entity.AmountUnit, "replay", entity.TransactionTypeStandard, 0, 0, 1, []string{approver.ID})
+	if _, err := acceptTransaction(replay); err == nil {
+		t.Error("acceptTransaction accepted a sequence used by a confirmed transaction")
+	}
+	if dag.hasTransaction(replay.ID) {
+		t.Error("the replay was added to the DAG")
+	}
+
+	// A synced replay is kept for its approvers, but rejected
+	ledgerMu.Lock()
+	acceptSyncedTransaction(replay)
+	ledgerMu.Unlock()
+	if got := ledgerStatuses([]string{replay.ID}); got[replay.ID] != entity.TransactionStatusRejected {
+		t.Errorf("synced replay is %q, want rejected", got[replay.ID])
+	}
+	if wallet.GetBalance() != 8*entity.AmountUnit {
+		t.Errorf("balance of A = %s, want 8", wallet.GetBalance())
+	}
+}
+
+func TestRestoreLedgerAppliesEachConfirmationOnce(t *testing.T) {
+	dataDir := t.TempDir()
+	newTestLedger(t, 2)
+	store = openTestStore(t, dataDir)
+	initDAG()
+	walletStore.PersistWallets()
+
+	spend := entity.NewTransaction(testAddressA, testAddressB, entity.AmountUnit, "", entity.TransactionTypeStandard, 0, 0, 1, []string{originTransaction.ID})
+	approver := entity.NewTransaction(testAddressB, testAddressA, 0, "", entity.TransactionTypeStandard, 0, 0, 1, []string{spend.ID})
+	for _, transaction := range []entity.Transaction{spend, approver} {
+		if _, err := acceptTransaction(transaction); err != nil {
+			t.Fatalf("acceptTransaction: %v", err)
+		}
+	}
+
+	// A crash right after saving the confirmed status leaves the pending
+	// record of the spend behind
+	if err := store.AddPendingTransaction(spend.ID); err != nil {
+		t.Fatalf("AddPendingTransaction: %v", err)
+	}
+	closeTestStore(t, store.(*FileStore))
+
+	walletStore = NewWalletStore(originTransactions)
+	pendingTransactions = NewPendingTransactions()
+	pendingSpends = NewPendingSpendStore()
+	store = openTestStore(t, dataDir)
+	defer closeTestStore(t, store.(*FileStore))
+
+	restored, err := restoreLedger()
+	if err != nil || !restored {
+		t.Fatalf("restoreLedger = %v, %v", restored, err)
+	}
+	ledgerMu.Lock()
+	confirmTransactions()
+	ledgerMu.Unlock()
+
+	walletA, _ := walletStore.GetWallet(testAddressA)
+	walletB, _ := walletStore.GetWallet(testAddressB)
+	if walletA.GetBalance() != 9*entity.AmountUnit || walletB.GetBalance() != 2*entity.AmountUnit {
+		t.Errorf("balances = %s and %s, want 9 and 2", walletA.GetBalance(), walletB.GetBalance())
+	}
+	if walletA.GetSequence() != 1 || walletB.GetSequence() != 1 {
+		t.Errorf("sequences = %d and %d, want 1 and 1", walletA.GetSequence(), walletB.GetSequence())
+	}
+	if history := walletA.GetTransactionHistory(); len(history) != 2 || history[1].ID != spend.ID {
+		t.Errorf("history of A = %v, want the origin transaction and the spend", history)
+	}
+	if _, pending := pendingTransactions.GetTransaction(spend.ID); pending {
+		t.Error("the confirmed spend is back in the pending pool")
+	}
+	if got := ledgerStatuses([]string{spend.ID}); got[spend.ID] != entity.TransactionStatusConfirmed {
+		t.Errorf("spend is %q, want confirmed", got[spend.ID])
+	}
+}
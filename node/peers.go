@@ -0,0 +1,96 @@
+package node
+
+import (
+	"context"
+	"energy/config"
+	"energy/domain/entity"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Wallets spread their requests over several nodes. A node asks every peer it
+// finds for the public URL of its API with the info request of the sync
+// protocol, and lists the URLs of its connected peers so that the wallets of
+// one node can find the others.
+
+// peerInfoTimeout bounds an info request made while answering the API.
+const peerInfoTimeout = 2 * time.Second
+
+type PeerURLs struct {
+	urls map[string]string // Peer ID -> public URL, empty when the peer does not advertise it
+	mu   sync.Mutex
+}
+
+var peerURLs = &PeerURLs{urls: make(map[string]string)}
+
+func (p *PeerURLs) get(peerID string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	publicURL, known := p.urls[peerID]
+	return publicURL, known
+}
+
+func (p *PeerURLs) set(peerID string, publicURL string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.urls[peerID] = publicURL
+}
+
+func (e PubsubInputImpl) InfoRequest() entity.NodeInfo {
+	return entity.NodeInfo{GenesisHash: genesisHash, PublicURL: nodeConfig.PublicURL}
+}
+
+// learnPeerURL asks a peer for the public URL of its API and remembers it.
+func learnPeerURL(ctx context.Context, peerID string) (string, error) {
+	info, err := pubsubOutput.RequestInfo(ctx, peerID)
+	if err != nil {
+		return "", err
+	}
+	publicURL := info.PublicURL
+	if publicURL != "" {
+		if publicURL, err = config.NormalizeNodeURL(publicURL); err != nil {
+			return "", err
+		}
+	}
+	peerURLs.set(peerID, publicURL)
+	return publicURL, nil
+}
+
+func getHealthHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "ok",
+		"genesis":      genesisHash,
+		"transactions": dag.countTransactions(),
+		"peers":        len(pubsubOutput.Peers()),
+	})
+}
+
+// getPeersHandler lists the public URLs of the APIs of the connected peers.
+func getPeersHandler(c *gin.Context) {
+	urls := make([]string, 0)
+	for _, peerID := range pubsubOutput.Peers() {
+		publicURL, known := peerURLs.get(peerID)
+		if !known {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), peerInfoTimeout)
+			var err error
+			publicURL, err = learnPeerURL(ctx, peerID)
+			cancel()
+			if err != nil {
+				// Do not ask again on every request, the peer is asked
+				// again when it is found
+				log.Printf("Error requesting info of peer %s: %v", peerID, err)
+				peerURLs.set(peerID, "")
+				continue
+			}
+		}
+		if publicURL != "" && !slices.Contains(urls, publicURL) {
+			urls = append(urls, publicURL)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"peers": urls})
+}
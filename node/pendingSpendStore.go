@@ -0,0 +1,76 @@
+package node
+
+import (
+	"energy/domain/entity"
+	"sort"
+	"sync"
+)
+
+// PendingSpendStore tracks the tokens that pending transactions will take from
+// each sender once they are confirmed.
+type PendingSpendStore struct {
+	Mu     sync.RWMutex
+	Spends map[string]map[string]entity.Amount // Sender address -> transaction ID -> token + fee
+}
+
+func NewPendingSpendStore() *PendingSpendStore {
+	return &PendingSpendStore{
+		Spends: make(map[string]map[string]entity.Amount),
+	}
+}
+
+func (ps *PendingSpendStore) AddSpend(transaction *entity.Transaction) error {
+	amount, err := transaction.TotalAmount()
+	if err != nil {
+		return err
+	}
+
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+
+	if _, exists := ps.Spends[transaction.From]; !exists {
+		ps.Spends[transaction.From] = make(map[string]entity.Amount)
+	}
+	ps.Spends[transaction.From][transaction.ID] = amount
+	return nil
+}
+
+func (ps *PendingSpendStore) RemoveSpend(transaction *entity.Transaction) {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+
+	delete(ps.Spends[transaction.From], transaction.ID)
+	if len(ps.Spends[transaction.From]) == 0 {
+		delete(ps.Spends, transaction.From)
+	}
+}
+
+// GetPendingOutgoing returns the total amount the pending transactions of an
+// address will spend.
+func (ps *PendingSpendStore) GetPendingOutgoing(address string) (entity.Amount, error) {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+
+	var total entity.Amount
+	for _, amount := range ps.Spends[address] {
+		var err error
+		total, err = total.Add(amount)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+// GetSpendIDs returns the sorted IDs of the pending transactions of an address.
+func (ps *PendingSpendStore) GetSpendIDs(address string) []string {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+
+	ids := make([]string, 0, len(ps.Spends[address]))
+	for id := range ps.Spends[address] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,129 @@
+package node
+
+import (
+	"energy/config"
+	"energy/domain/entity"
+	"energy/domain/entity/request"
+	"energy/pow"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The PoW service mines standard transactions for devices that cannot afford
+// it. It is disabled unless configured. Every client, identified by its IP
+// address, may ask for a limited number of proofs per quota window, and at
+// most a fixed number of proofs are mined at the same time.
+
+// powQuotaWindow is the period over which the requests of a client are counted.
+const powQuotaWindow = time.Hour
+
+// PowQuota counts the recent PoW requests of every client.
+type PowQuota struct {
+	mu       sync.Mutex
+	requests map[string][]time.Time
+}
+
+func NewPowQuota() *PowQuota {
+	return &PowQuota{
+		requests: make(map[string][]time.Time),
+	}
+}
+
+// Allow records a request of the client and reports whether it is within the
+// limit of requests per quota window.
+func (pq *PowQuota) Allow(client string, limit int) bool {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+
+	cutoff := time.Now().Add(-powQuotaWindow)
+	for address, requests := range pq.requests {
+		recent := requests[:0]
+		for _, requested := range requests {
+			if requested.After(cutoff) {
+				recent = append(recent, requested)
+			}
+		}
+		if len(recent) == 0 {
+			delete(pq.requests, address)
+		} else {
+			pq.requests[address] = recent
+		}
+	}
+
+	if len(pq.requests[client]) >= limit {
+		return false
+	}
+	pq.requests[client] = append(pq.requests[client], time.Now())
+	return true
+}
+
+var powQuota = NewPowQuota()
+
+// powWorkers holds a token for every proof being mined.
+var powWorkers chan struct{}
+
+func initPowService(nodeCfg config.NodeConfig) error {
+	if !nodeCfg.PowService {
+		return nil
+	}
+	if nodeCfg.PowServiceWorkers < 1 {
+		return errors.New("PoW service workers must be at least 1")
+	}
+	if nodeCfg.PowServiceQuota < 1 {
+		return errors.New("PoW service quota must be at least 1")
+	}
+
+	powWorkers = make(chan struct{}, nodeCfg.PowServiceWorkers)
+	log.Printf("PoW service enabled with %d workers", nodeCfg.PowServiceWorkers)
+
+	return nil
+}
+
+func postPowHandler(c *gin.Context) {
+	var powRequest request.PowNodeRequest
+
+	if err := c.ShouldBindJSON(&powRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transaction := powRequest.Transaction
+	if transaction.Type != entity.TransactionTypeStandard {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only standard transactions need PoW"})
+		return
+	}
+	if !entity.IsValidAddress(transaction.From) || !entity.IsValidAddress(transaction.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid address"})
+		return
+	}
+
+	if !powQuota.Allow(c.ClientIP(), nodeConfig.PowServiceQuota) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "PoW quota exceeded"})
+		return
+	}
+
+	select {
+	case powWorkers <- struct{}{}:
+		defer func() { <-powWorkers }()
+	default:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "all PoW workers are busy"})
+		return
+	}
+
+	difficulty := pow.Difficulty(len(transaction.Data), advertiseDifficultyAdjustment())
+	result, err := pow.Mine(c.Request.Context(), transaction.PowPreimage(), difficulty, 1, nodeConfig.PowServiceMaxIterations)
+	if err != nil {
+		log.Println("Error performing PoW for", c.ClientIP(), err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"nonce":      result.Nonce,
+		"difficulty": difficulty,
+	})
+}
@@ -0,0 +1,84 @@
+package node
+
+import (
+	"energy/domain/entity"
+	"log"
+)
+
+// Store persists the ledger of the node: the transactions of the DAG, the
+// addresses of the wallets and the IDs of the pending pool. SaveWallet only
+// saves the address of a wallet. Balances, sequences and histories are derived
+// from the saved transactions when the ledger is restored, so that the status
+// record of a transaction is all that confirming it writes for good.
+type Store interface {
+	SaveTransaction(transaction entity.Transaction) error
+	LoadTransactions() ([]entity.Transaction, error)
+	SaveWallet(wallet *entity.Wallet) error
+	LoadWallets() ([]*entity.Wallet, error)
+	AddPendingTransaction(id string) error
+	DeletePendingTransaction(id string) error
+	LoadPendingTransactions() ([]string, error)
+	Close() error
+}
+
+var store Store = NewMemoryStore()
+
+// OpenStore opens the on-disk store in the data directory, or an in-memory
+// store that forgets everything on restart when no directory is given.
+func OpenStore(dataDir string) (Store, error) {
+	if dataDir == "" {
+		return NewMemoryStore(), nil
+	}
+	return NewFileStore(dataDir)
+}
+
+func persistTransaction(transaction entity.Transaction) {
+	if err := store.SaveTransaction(transaction); err != nil {
+		log.Printf("Error persisting transaction %s: %v", transaction.ID, err)
+	}
+}
+
+func persistWallet(wallet *entity.Wallet) {
+	if err := store.SaveWallet(wallet); err != nil {
+		log.Printf("Error persisting wallet %s: %v", wallet.Address, err)
+	}
+}
+
+// MemoryStore keeps nothing, the ledger only lives in the node maps.
+type MemoryStore struct{}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) SaveTransaction(transaction entity.Transaction) error {
+	return nil
+}
+
+func (s *MemoryStore) LoadTransactions() ([]entity.Transaction, error) {
+	return nil, nil
+}
+
+func (s *MemoryStore) SaveWallet(wallet *entity.Wallet) error {
+	return nil
+}
+
+func (s *MemoryStore) LoadWallets() ([]*entity.Wallet, error) {
+	return nil, nil
+}
+
+func (s *MemoryStore) AddPendingTransaction(id string) error {
+	return nil
+}
+
+func (s *MemoryStore) DeletePendingTransaction(id string) error {
+	return nil
+}
+
+func (s *MemoryStore) LoadPendingTransactions() ([]string, error) {
+	return nil, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,243 @@
+package node
+
+import (
+	"context"
+	"energy/domain/entity"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// A node catches up with its peers through the sync protocol of the pubsub
+// layer. When a peer is found, the node asks for its tips and downloads every
+// transaction it is missing, walking back through the parents until it reaches
+// transactions it already knows. The same download runs when a gossiped
+// transaction references an unknown parent. The statuses reported by the peer
+// are ignored: downloaded transactions enter the DAG as pending, parents
+// first, and the local conflict detection and confirmation apply their
+// transfers. Balances and sequences are never taken from a peer. The senders
+// and recipients of downloaded transactions the node does not know yet are
+// created as empty wallets.
+
+// maxSyncBatch is the number of transactions requested at once.
+const maxSyncBatch = 100
+
+func (e PubsubInputImpl) PeerFound(peerID string) {
+	events.Publish(entity.Event{Type: entity.EventPeerJoined, Time: time.Now(), Peer: peerID})
+
+	go func() {
+		if _, err := learnPeerURL(nodeCtx, peerID); err != nil {
+			log.Printf("Error requesting info of peer %s: %v", peerID, err)
+		}
+		if err := catchUpWithPeer(nodeCtx, peerID); err != nil {
+			log.Printf("Error catching up with peer %s: %v", peerID, err)
+		}
+	}()
+}
+
+func (e PubsubInputImpl) PeerLost(peerID string) {
+	events.Publish(entity.Event{Type: entity.EventPeerLeft, Time: time.Now(), Peer: peerID})
+}
+
+func (e PubsubInputImpl) TipsRequest() []string {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+
+	return dag.getTipsLocked()
+}
+
+func (e PubsubInputImpl) TransactionsRequest(ids []string) []entity.Transaction {
+	transactions := make([]entity.Transaction, 0, len(ids))
+	for _, id := range ids {
+		if transaction, exists := dag.getTransactionByID(id); exists {
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions
+}
+
+func catchUpWithPeer(ctx context.Context, peerID string) error {
+	tips, err := pubsubOutput.RequestTips(ctx, peerID)
+	if err != nil {
+		return err
+	}
+
+	missing := make([]string, 0)
+	for _, tip := range tips {
+		if !dag.hasTransaction(tip) {
+			missing = append(missing, tip)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return syncTransactions(ctx, peerID, missing)
+}
+
+// syncMissingParents downloads the unknown parents of a transaction from the
+// first connected peer that has them.
+func syncMissingParents(ctx context.Context, transaction *entity.Transaction) error {
+	missing := make([]string, 0)
+	for _, parent := range transaction.Parents {
+		if !dag.hasTransaction(parent) {
+			missing = append(missing, parent)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	for _, peerID := range pubsubOutput.Peers() {
+		err := syncTransactions(ctx, peerID, missing)
+		if err == nil {
+			return nil
+		}
+		log.Printf("Error syncing missing parents from peer %s: %v", peerID, err)
+	}
+
+	return errors.New("no peer could provide the missing parents")
+}
+
+// syncTransactions downloads the given transactions and their unknown
+// ancestors from a peer and accepts them as pending.
+func syncTransactions(ctx context.Context, peerID string, ids []string) error {
+	downloaded := make(map[string]entity.Transaction)
+	queue := ids
+
+	for len(queue) > 0 {
+		batch := queue[:min(len(queue), maxSyncBatch)]
+		queue = queue[len(batch):]
+
+		transactions, err := pubsubOutput.RequestTransactions(ctx, peerID, batch)
+		if err != nil {
+			return err
+		}
+
+		for _, transaction := range transactions {
+			if !slices.Contains(batch, transaction.ID) || !entity.HasValidID(&transaction) {
+				return fmt.Errorf("peer sent an unexpected transaction %s", transaction.ID)
+			}
+			downloaded[transaction.ID] = transaction
+
+			for _, parent := range transaction.Parents {
+				_, queued := downloaded[parent]
+				if !queued && !dag.hasTransaction(parent) && !slices.Contains(queue, parent) {
+					queue = append(queue, parent)
+				}
+			}
+		}
+	}
+
+	transactions := make([]entity.Transaction, 0, len(downloaded))
+	for _, transaction := range downloaded {
+		transactions = append(transactions, transaction)
+	}
+
+	ledgerMu.Lock()
+	defer ledgerMu.Unlock()
+
+	added, created := 0, 0
+	for _, transaction := range sortTopologically(transactions) {
+		if dag.hasTransaction(transaction.ID) {
+			continue
+		}
+		transactionsReceived.WithLabelValues(sourceSync).Inc()
+
+		if err := validateSyncedTransaction(&transaction); err != nil {
+			recordValidationFailure(sourceSync, err)
+			return fmt.Errorf("invalid synced transaction %s: %v", transaction.ID, err)
+		}
+
+		for _, address := range []string{transaction.From, transaction.To} {
+			if _, exists := walletStore.GetWallet(address); !exists {
+				walletStore.SaveWallet(entity.NewWallet(address))
+				created++
+			}
+		}
+
+		acceptSyncedTransaction(transaction)
+		transactionsAccepted.WithLabelValues(sourceSync).Inc()
+		added++
+	}
+
+	log.Printf("Synced %d transactions and created %d wallets from peer %s", added, created, peerID)
+
+	return nil
+}
+
+// acceptSyncedTransaction adds a downloaded transaction like acceptTransaction.
+// A transaction reusing the sequence of a confirmed one is still added, since
+// the transactions approving it are synced too, but as rejected. The caller
+// must hold the ledger mutex.
+func acceptSyncedTransaction(transaction entity.Transaction) {
+	transaction.TimestampConfirmed = time.Time{}
+
+	if err := validateSequence(&transaction); err != nil {
+		log.Printf("Rejecting synced transaction %s: %v", transaction.ID, err)
+		transaction.UpdateStatus(entity.TransactionStatusRejected)
+		dag.addTransaction(transaction)
+		events.Publish(entity.NewTransactionEvent(entity.EventTransactionRejected, transaction))
+		return
+	}
+	walletStore.RaiseSequence(transaction.From, transaction.Sequence)
+
+	transaction.UpdateStatus(entity.TransactionStatusPending)
+
+	detectConflict(&transaction)
+
+	addPendingTransaction(transaction)
+
+	dag.addTransaction(transaction)
+
+	confirmTransactions()
+}
+
+// validateSyncedTransaction checks the integrity of a transaction downloaded
+// from a peer. Balances and sequences are not checked, they are applied by the
+// local confirmation.
+func validateSyncedTransaction(transaction *entity.Transaction) error {
+	if transaction.Type == entity.TransactionTypeOrigin {
+		return invalid(reasonType, errors.New("unknown origin transaction"))
+	}
+
+	if !entity.IsValidAddress(transaction.From) {
+		return invalid(reasonAddress, errors.New("invalid transaction FROM address"))
+	}
+
+	if !entity.IsValidAddress(transaction.To) {
+		return invalid(reasonAddress, errors.New("invalid transaction TO address"))
+	}
+
+	if !entity.IsValidTransactionType(transaction.Type) {
+		return invalid(reasonType, errors.New("invalid transaction type"))
+	}
+
+	if !entity.IsValidSignature(transaction) {
+		return invalid(reasonSignature, errors.New("invalid transaction signature"))
+	}
+
+	if transaction.Type == entity.TransactionTypeStandard && !verifyProofOfWork(transaction, baseDifficultyAdjustment()) {
+		return invalid(reasonPow, errors.New("invalid PoW"))
+	}
+
+	if _, err := transaction.TotalAmount(); err != nil {
+		return invalid(reasonAmount, err)
+	}
+
+	if transaction.Type == entity.TransactionTypeFast && !entity.IsValidFee(transaction.Fee, transaction.Data) {
+		return invalid(reasonFee, errors.New("invalid transaction fee"))
+	}
+
+	for _, parent := range transaction.Parents {
+		if !dag.hasTransaction(parent) {
+			return invalid(reasonParents, errors.New("unknown transaction parent "+parent))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package node
+
+import (
+	"energy/domain/entity"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+const (
+	TipSelectionOldest             string = "oldest"
+	TipSelectionRandom             string = "random"
+	TipSelectionWeightedRandomWalk string = "weighted-random-walk"
+)
+
+// TipSelector chooses the transactions that a new transaction approves.
+type TipSelector interface {
+	SelectTips() []entity.Transaction
+}
+
+var tipSelector TipSelector
+
+func NewTipSelector(strategy string, alpha float64) (TipSelector, error) {
+	switch strategy {
+	case TipSelectionOldest:
+		return oldestTipSelector{}, nil
+	case TipSelectionRandom:
+		return randomTipSelector{}, nil
+	case TipSelectionWeightedRandomWalk:
+		if alpha < 0 {
+			return nil, fmt.Errorf("tip selection alpha must not be negative")
+		}
+		return weightedRandomWalkTipSelector{alpha: alpha}, nil
+	default:
+		return nil, fmt.Errorf("unknown tip selection strategy %q", strategy)
+	}
+}
+
+// oldestTipSelector always hands out the oldest pending transactions.
+type oldestTipSelector struct{}
+
+func (s oldestTipSelector) SelectTips() []entity.Transaction {
+	tips := pendingTransactions.GetOldestTransactions()
+	if len(tips) == 0 {
+		// Everything is confirmed, approve the newest transactions instead
+		return randomTipSelector{}.SelectTips()
+	}
+	return tips
+}
+
+// randomTipSelector picks tips uniformly at random.
+type randomTipSelector struct{}
+
+func (s randomTipSelector) SelectTips() []entity.Transaction {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+
+	tips := dag.getTipsLocked()
+	rand.Shuffle(len(tips), func(i, j int) {
+		tips[i], tips[j] = tips[j], tips[i]
+	})
+
+	selectedTips := make([]entity.Transaction, 0, maxParents)
+	for _, tip := range tips {
+		if len(selectedTips) == maxParents {
+			break
+		}
+		selectedTips = append(selectedTips, dag.Transactions[tip])
+	}
+	return selectedTips
+}
+
+// walkDepth is the number of parents a walk steps back from the last added
+// transaction before walking towards the tips, so that the cost of a walk does
+// not grow with the DAG.
+const walkDepth = 15
+
+// weightedRandomWalkTipSelector walks towards the tips from a transaction a few
+// random parents below the last added transaction, moving to each approver
+// with a probability that grows with its cumulative weight. Alpha controls how
+// strongly heavier branches are preferred; zero gives an unweighted random
+// walk. Tips left behind below the entry point are never selected again.
+type weightedRandomWalkTipSelector struct {
+	alpha float64
+}
+
+func (s weightedRandomWalkTipSelector) SelectTips() []entity.Transaction {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+
+	selectedTips := make([]entity.Transaction, 0, maxParents)
+	selected := make(map[string]bool, maxParents)
+
+	// Walks may end on the same tip, so try a few more times than needed
+	for attempt := 0; attempt < 4*maxParents && len(selectedTips) < maxParents; attempt++ {
+		tip := s.walk(walkEntryPoint(dag.latest))
+		if selected[tip] {
+			continue
+		}
+		selected[tip] = true
+		selectedTips = append(selectedTips, dag.Transactions[tip])
+	}
+	return selectedTips
+}
+
+// walkEntryPoint steps back walkDepth random parents from a transaction, or
+// fewer when it reaches the origin, and further while it is on rejected
+// transactions. The caller must hold the DAG mutex.
+func walkEntryPoint(start string) string {
+	current := start
+	for i := 0; i < walkDepth || dag.Transactions[current].Status == entity.TransactionStatusRejected; i++ {
+		parents := dag.parents[current]
+		if len(parents) == 0 {
+			break
+		}
+		current = parents[rand.Intn(len(parents))]
+	}
+	return current
+}
+
+func (s weightedRandomWalkTipSelector) walk(start string) string {
+	current := start
+	for {
+		approvers := dag.getValidApproversLocked(current)
+		if len(approvers) == 0 {
+			return current
+		}
+
+		weights := make([]float64, len(approvers))
+		maxWeight := 0
+		for i, approver := range approvers {
+			weight := dag.weights[approver]
+			weights[i] = float64(weight)
+			maxWeight = max(maxWeight, weight)
+		}
+
+		// Transition probability is proportional to exp(alpha * weight), shifted by
+		// the maximum weight to avoid overflowing
+		total := 0.0
+		for i := range weights {
+			weights[i] = math.Exp(s.alpha * (weights[i] - float64(maxWeight)))
+			total += weights[i]
+		}
+
+		next := approvers[len(approvers)-1]
+		target := rand.Float64() * total
+		for i, weight := range weights {
+			target -= weight
+			if target < 0 {
+				next = approvers[i]
+				break
+			}
+		}
+		current = next
+	}
+}
@@ -0,0 +1,328 @@
+package node
+
+import (
+	"encoding/base64"
+	"energy/domain/entity"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The DAG keeps secondary indexes of its transactions so that queries do not
+// scan every transaction. Set indexes map a status, a type or an address to
+// the IDs of its transactions, time indexes keep the IDs sorted by each
+// timestamp. A query walks the time index of its sort order between the
+// bounds of its time range and cursor, unless one of its filters selects
+// fewer transactions, which are then sorted instead.
+
+// Timestamps a query can sort and filter by.
+const (
+	QueryTimeCreated   string = "created"
+	QueryTimeAdded     string = "added"
+	QueryTimeConfirmed string = "confirmed"
+)
+
+var queryTimes = []string{QueryTimeCreated, QueryTimeAdded, QueryTimeConfirmed}
+
+type TransactionIndex struct {
+	byStatus map[string]map[string]bool
+	byType   map[string]map[string]bool
+	byFrom   map[string]map[string]bool
+	byTo     map[string]map[string]bool
+	// Query time -> transactions having that timestamp, in ascending order
+	byTime map[string][]indexKey
+}
+
+// indexKey orders transactions by a timestamp, then by ID.
+type indexKey struct {
+	time int64
+	id   string
+}
+
+func (k indexKey) less(other indexKey) bool {
+	return k.time < other.time || (k.time == other.time && k.id < other.id)
+}
+
+// next returns the smallest key greater than k.
+func (k indexKey) next() indexKey {
+	return indexKey{time: k.time, id: k.id + "\x00"}
+}
+
+func newTransactionIndex() TransactionIndex {
+	return TransactionIndex{
+		byStatus: make(map[string]map[string]bool),
+		byType:   make(map[string]map[string]bool),
+		byFrom:   make(map[string]map[string]bool),
+		byTo:     make(map[string]map[string]bool),
+		byTime:   make(map[string][]indexKey),
+	}
+}
+
+func (index *TransactionIndex) add(transaction entity.Transaction) {
+	addToSet(index.byStatus, transaction.Status, transaction.ID)
+	addToSet(index.byType, transaction.Type, transaction.ID)
+	addToSet(index.byFrom, transaction.From, transaction.ID)
+	addToSet(index.byTo, transaction.To, transaction.ID)
+
+	for _, queryTime := range queryTimes {
+		key, ok := transactionKey(transaction, queryTime)
+		if !ok {
+			continue
+		}
+		keys := index.byTime[queryTime]
+		position := sort.Search(len(keys), func(i int) bool { return !keys[i].less(key) })
+		keys = append(keys, indexKey{})
+		copy(keys[position+1:], keys[position:])
+		keys[position] = key
+		index.byTime[queryTime] = keys
+	}
+}
+
+func (index *TransactionIndex) remove(transaction entity.Transaction) {
+	removeFromSet(index.byStatus, transaction.Status, transaction.ID)
+	removeFromSet(index.byType, transaction.Type, transaction.ID)
+	removeFromSet(index.byFrom, transaction.From, transaction.ID)
+	removeFromSet(index.byTo, transaction.To, transaction.ID)
+
+	for _, queryTime := range queryTimes {
+		key, ok := transactionKey(transaction, queryTime)
+		if !ok {
+			continue
+		}
+		keys := index.byTime[queryTime]
+		position := sort.Search(len(keys), func(i int) bool { return !keys[i].less(key) })
+		if position < len(keys) && keys[position] == key {
+			index.byTime[queryTime] = append(keys[:position], keys[position+1:]...)
+		}
+	}
+}
+
+func addToSet(sets map[string]map[string]bool, value string, id string) {
+	if sets[value] == nil {
+		sets[value] = make(map[string]bool)
+	}
+	sets[value][id] = true
+}
+
+func removeFromSet(sets map[string]map[string]bool, value string, id string) {
+	delete(sets[value], id)
+	if len(sets[value]) == 0 {
+		delete(sets, value)
+	}
+}
+
+// transactionKey returns the index key of a transaction for a query time,
+// false when the transaction does not have that timestamp yet.
+func transactionKey(transaction entity.Transaction, queryTime string) (indexKey, bool) {
+	var timestamp time.Time
+	switch queryTime {
+	case QueryTimeCreated:
+		timestamp = transaction.TimestampCreated
+	case QueryTimeAdded:
+		timestamp = transaction.TimestampAdded
+	case QueryTimeConfirmed:
+		timestamp = transaction.TimestampConfirmed
+	}
+	if timestamp.IsZero() {
+		return indexKey{}, false
+	}
+	return indexKey{time: timestamp.UnixNano(), id: transaction.ID}, true
+}
+
+// TimeRange selects the timestamps from After, included, to Before, excluded.
+// Zero bounds are open.
+type TimeRange struct {
+	After  time.Time
+	Before time.Time
+}
+
+type TransactionQuery struct {
+	// Empty filters match every transaction
+	Status string
+	Type   string
+	From   string
+	To     string
+	// Query time -> range its timestamp must lie in
+	Ranges map[string]TimeRange
+	// Query time the results are sorted by. Transactions without that
+	// timestamp are left out.
+	SortBy     string
+	Descending bool
+	Limit      int
+	// Cursor returned with the previous page, empty for the first page
+	Cursor string
+}
+
+func (dag *DAG) queryTransactions(query TransactionQuery) ([]entity.Transaction, string, error) {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+	return dag.queryTransactionsLocked(query)
+}
+
+// queryTransactionsLocked returns a page of the transactions matching the
+// query and the cursor of the next page, empty after the last page. The
+// caller must hold the DAG mutex.
+func (dag *DAG) queryTransactionsLocked(query TransactionQuery) ([]entity.Transaction, string, error) {
+	lower, upper, err := query.bounds()
+	if err != nil {
+		return nil, "", err
+	}
+
+	keys := dag.index.byTime[query.SortBy]
+	start := sort.Search(len(keys), func(i int) bool { return !keys[i].less(lower) })
+	end := len(keys)
+	if upper != nil {
+		end = sort.Search(len(keys), func(i int) bool { return !keys[i].less(*upper) })
+	}
+
+	// A page holds one more transaction than asked to know if there is a next page
+	page := make([]indexKey, 0, query.Limit+1)
+
+	candidates := dag.smallestCandidateSetLocked(query)
+	if candidates != nil && len(candidates) < end-start {
+		for id := range candidates {
+			key, ok := transactionKey(dag.Transactions[id], query.SortBy)
+			if !ok || key.less(lower) || (upper != nil && !key.less(*upper)) {
+				continue
+			}
+			if dag.matchesLocked(id, query) {
+				page = append(page, key)
+			}
+		}
+		sort.Slice(page, func(i, j int) bool { return page[i].less(page[j]) != query.Descending })
+		if len(page) > query.Limit+1 {
+			page = page[:query.Limit+1]
+		}
+	} else if query.Descending {
+		for i := end - 1; i >= start && len(page) <= query.Limit; i-- {
+			if dag.matchesLocked(keys[i].id, query) {
+				page = append(page, keys[i])
+			}
+		}
+	} else {
+		for i := start; i < end && len(page) <= query.Limit; i++ {
+			if dag.matchesLocked(keys[i].id, query) {
+				page = append(page, keys[i])
+			}
+		}
+	}
+
+	nextCursor := ""
+	if len(page) > query.Limit {
+		page = page[:query.Limit]
+		nextCursor = encodeCursor(page[len(page)-1])
+	}
+
+	transactions := make([]entity.Transaction, 0, len(page))
+	for _, key := range page {
+		transactions = append(transactions, dag.Transactions[key.id])
+	}
+	return transactions, nextCursor, nil
+}
+
+// bounds returns the keys of the sort order the page lies between, the lower
+// one included and the upper one, nil when open, excluded.
+func (query TransactionQuery) bounds() (indexKey, *indexKey, error) {
+	lower := indexKey{time: math.MinInt64}
+	var upper *indexKey
+
+	if timeRange, ok := query.Ranges[query.SortBy]; ok {
+		if !timeRange.After.IsZero() {
+			lower = indexKey{time: timeRange.After.UnixNano()}
+		}
+		if !timeRange.Before.IsZero() {
+			upper = &indexKey{time: timeRange.Before.UnixNano()}
+		}
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return lower, upper, err
+		}
+		if query.Descending {
+			if upper == nil || cursor.less(*upper) {
+				upper = &cursor
+			}
+		} else if lower.less(cursor.next()) {
+			lower = cursor.next()
+		}
+	}
+
+	return lower, upper, nil
+}
+
+// smallestCandidateSetLocked returns the smallest set index selected by the
+// filters of the query, nil without filters. The caller must hold the DAG
+// mutex.
+func (dag *DAG) smallestCandidateSetLocked(query TransactionQuery) map[string]bool {
+	var smallest map[string]bool
+	filters := []struct {
+		sets  map[string]map[string]bool
+		value string
+	}{
+		{dag.index.byStatus, query.Status},
+		{dag.index.byType, query.Type},
+		{dag.index.byFrom, query.From},
+		{dag.index.byTo, query.To},
+	}
+	for _, filter := range filters {
+		if filter.value == "" {
+			continue
+		}
+		set := filter.sets[filter.value]
+		if set == nil {
+			return map[string]bool{}
+		}
+		if smallest == nil || len(set) < len(smallest) {
+			smallest = set
+		}
+	}
+	return smallest
+}
+
+// matchesLocked checks a transaction against every filter of the query. The
+// caller must hold the DAG mutex.
+func (dag *DAG) matchesLocked(id string, query TransactionQuery) bool {
+	transaction := dag.Transactions[id]
+
+	if (query.Status != "" && transaction.Status != query.Status) ||
+		(query.Type != "" && transaction.Type != query.Type) ||
+		(query.From != "" && transaction.From != query.From) ||
+		(query.To != "" && transaction.To != query.To) {
+		return false
+	}
+
+	for queryTime, timeRange := range query.Ranges {
+		key, ok := transactionKey(transaction, queryTime)
+		if !ok {
+			return false
+		}
+		if (!timeRange.After.IsZero() && key.time < timeRange.After.UnixNano()) ||
+			(!timeRange.Before.IsZero() && key.time >= timeRange.Before.UnixNano()) {
+			return false
+		}
+	}
+	return true
+}
+
+func encodeCursor(key indexKey) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(key.time, 10) + ":" + key.id))
+}
+
+func decodeCursor(cursor string) (indexKey, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return indexKey{}, errors.New("invalid cursor")
+	}
+
+	timePart, id, found := strings.Cut(string(decoded), ":")
+	timestamp, err := strconv.ParseInt(timePart, 10, 64)
+	if !found || err != nil || !entity.IsValidID(id) {
+		return indexKey{}, errors.New("invalid cursor")
+	}
+	return indexKey{time: timestamp, id: id}, nil
+}
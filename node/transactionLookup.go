@@ -0,0 +1,195 @@
+package node
+
+import (
+	"energy/domain/entity"
+	"energy/domain/entity/request"
+	"energy/domain/entity/response"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// maxLookupTransactions is the number of transactions a batch lookup accepts.
+const maxLookupTransactions = 500
+
+// Page sizes of the transaction query.
+const (
+	defaultQueryLimit = 100
+	maxQueryLimit     = 1000
+)
+
+// lookupTransaction returns a transaction with its status, its neighbours in
+// the DAG and its cumulative weight.
+func lookupTransaction(id string) (response.TransactionStatusResponse, bool) {
+	transaction, exists := dag.getTransactionByID(id)
+	if !exists {
+		return response.TransactionStatusResponse{}, false
+	}
+
+	status := response.TransactionStatusResponse{
+		Transaction:      transaction,
+		Status:           transaction.Status,
+		Parents:          append([]string{}, dag.getParents(id)...),
+		Approvers:        append([]string{}, dag.getApprovers(id)...),
+		CumulativeWeight: dag.getCumulativeWeight(id),
+	}
+	if !transaction.TimestampConfirmed.IsZero() {
+		timestampConfirmed := transaction.TimestampConfirmed
+		status.TimestampConfirmed = &timestampConfirmed
+	}
+	return status, true
+}
+
+func getTransactionHandler(c *gin.Context) {
+	id := c.Param("id")
+
+	if !entity.IsValidID(id) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	status, exists := lookupTransaction(id)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// postTransactionLookupHandler looks up many transactions at once. The IDs
+// that are not in the DAG are listed as missing.
+func postTransactionLookupHandler(c *gin.Context) {
+	var lookupRequest request.TransactionLookupRequest
+
+	if err := c.ShouldBindJSON(&lookupRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(lookupRequest.IDs) > maxLookupTransactions {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d transactions can be looked up at once", maxLookupTransactions)})
+		return
+	}
+
+	transactions := make([]response.TransactionStatusResponse, 0, len(lookupRequest.IDs))
+	missing := make([]string, 0)
+	seen := make(map[string]bool, len(lookupRequest.IDs))
+	for _, id := range lookupRequest.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if status, exists := lookupTransaction(id); exists {
+			transactions = append(transactions, status)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transactions": transactions,
+		"missing":      missing,
+	})
+}
+
+// getTransactionsHandler returns a page of the transactions matching the
+// filters of the query string, sorted by one of their timestamps.
+func getTransactionsHandler(c *gin.Context) {
+	query, err := parseTransactionQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transactions, nextCursor, err := dag.queryTransactions(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page := gin.H{"transactions": transactions}
+	if nextCursor != "" {
+		page["nextCursor"] = nextCursor
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func parseTransactionQuery(c *gin.Context) (TransactionQuery, error) {
+	query := TransactionQuery{
+		Status: c.Query("status"),
+		Type:   c.Query("type"),
+		From:   c.Query("from"),
+		To:     c.Query("to"),
+		Ranges: make(map[string]TimeRange),
+		SortBy: c.DefaultQuery("sort", QueryTimeCreated),
+		Limit:  defaultQueryLimit,
+		Cursor: c.Query("cursor"),
+	}
+
+	if query.Status != "" && !entity.IsValidTransactionStatus(query.Status) {
+		return query, fmt.Errorf("invalid status %q", query.Status)
+	}
+	if query.Type != "" && !entity.IsValidTransactionType(query.Type) && query.Type != entity.TransactionTypeOrigin {
+		return query, fmt.Errorf("invalid type %q", query.Type)
+	}
+	if query.From != "" && !entity.IsValidAddress(query.From) {
+		return query, errors.New("invalid from address")
+	}
+	if query.To != "" && !entity.IsValidAddress(query.To) {
+		return query, errors.New("invalid to address")
+	}
+	if !slices.Contains(queryTimes, query.SortBy) {
+		return query, fmt.Errorf("invalid sort %q, expected created, added or confirmed", query.SortBy)
+	}
+
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, errors.New("invalid order, expected asc or desc")
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > maxQueryLimit {
+			return query, fmt.Errorf("limit must be between 1 and %d", maxQueryLimit)
+		}
+		query.Limit = parsed
+	}
+
+	// e.g. createdAfter and createdBefore bound TimestampCreated
+	for _, queryTime := range queryTimes {
+		var timeRange TimeRange
+		var err error
+		if timeRange.After, err = parseQueryTime(c, queryTime+"After"); err != nil {
+			return query, err
+		}
+		if timeRange.Before, err = parseQueryTime(c, queryTime+"Before"); err != nil {
+			return query, err
+		}
+		if timeRange != (TimeRange{}) {
+			query.Ranges[queryTime] = timeRange
+		}
+	}
+
+	return query, nil
+}
+
+func parseQueryTime(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s, expected an RFC 3339 time", name)
+	}
+	return parsed, nil
+}
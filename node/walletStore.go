@@ -3,6 +3,7 @@ package node
 import (
 	"energy/domain/entity"
 	"fmt"
+	"sort"
 	"sync"
 )
 
@@ -14,36 +15,40 @@ type WalletStore struct {
 func (ws *WalletStore) SaveWallet(wallet *entity.Wallet) {
 	ws.Mu.Lock()
 	defer ws.Mu.Unlock()
-	ws.Wallets[wallet.Seed] = wallet
+	ws.Wallets[wallet.Address] = wallet
+	persistWallet(wallet)
 }
 
-func (ws *WalletStore) GetWallet(seed string) (*entity.Wallet, bool) {
+func (ws *WalletStore) GetWallet(address string) (*entity.Wallet, bool) {
 	ws.Mu.RLock()
 	defer ws.Mu.RUnlock()
-	wallet, ok := ws.Wallets[seed]
+	wallet, ok := ws.Wallets[address]
 	return wallet, ok
 }
 
-func (ws *WalletStore) IncreaseBalance(seed string, token float64) error {
+func (ws *WalletStore) IncreaseBalance(address string, token entity.Amount) error {
 	ws.Mu.Lock()
 	defer ws.Mu.Unlock()
 
-	wallet, exists := ws.Wallets[seed]
+	wallet, exists := ws.Wallets[address]
 	if !exists {
 		return fmt.Errorf("wallet not found")
 	}
 
-	wallet.IncreaseBalance(token)
-	ws.Wallets[seed] = wallet
+	if err := wallet.IncreaseBalance(token); err != nil {
+		return err
+	}
+	ws.Wallets[address] = wallet
+	persistWallet(wallet)
 
 	return nil
 }
 
-func (ws *WalletStore) DecreaseBalance(seed string, token float64) error {
+func (ws *WalletStore) DecreaseBalance(address string, token entity.Amount) error {
 	ws.Mu.Lock()
 	defer ws.Mu.Unlock()
 
-	wallet, exists := ws.Wallets[seed]
+	wallet, exists := ws.Wallets[address]
 	if !exists {
 		return fmt.Errorf("wallet not found")
 	}
@@ -53,22 +58,115 @@ func (ws *WalletStore) DecreaseBalance(seed string, token float64) error {
 		return err
 	}
 
-	ws.Wallets[seed] = wallet
+	ws.Wallets[address] = wallet
+	persistWallet(wallet)
 
 	return nil
 }
 
-func (ws *WalletStore) AddTransactionToHistory(seed string, transaction *entity.Transaction) error {
+// RaiseSequence raises the sequence of an address to the given one when it is
+// higher, creating its wallet when the node does not know it yet.
+func (ws *WalletStore) RaiseSequence(address string, sequence uint64) {
 	ws.Mu.Lock()
 	defer ws.Mu.Unlock()
 
-	wallet, exists := ws.Wallets[seed]
+	wallet, exists := ws.Wallets[address]
+	if !exists {
+		wallet = entity.NewWallet(address)
+		ws.Wallets[address] = wallet
+	}
+
+	if wallet.RaiseSequence(sequence) {
+		persistWallet(wallet)
+	}
+}
+
+func (ws *WalletStore) AddTransactionToHistory(address string, transaction *entity.Transaction) error {
+	ws.Mu.Lock()
+	defer ws.Mu.Unlock()
+
+	wallet, exists := ws.Wallets[address]
 	if !exists {
 		return fmt.Errorf("wallet not found")
 	}
 
 	wallet.AddTransactionToHistory(transaction)
-	ws.Wallets[seed] = wallet
+	ws.Wallets[address] = wallet
+
+	return nil
+}
+
+// PersistWallets saves every wallet in the store. Only a new ledger is
+// persisted this way.
+func (ws *WalletStore) PersistWallets() {
+	ws.Mu.RLock()
+	defer ws.Mu.RUnlock()
+
+	for _, wallet := range ws.Wallets {
+		persistWallet(wallet)
+	}
+}
+
+// RestoreWallets replaces the wallets with the ones loaded from the store and
+// derives their state from the stored transactions. Every transaction raises
+// the sequence of its sender, and every confirmed transaction, in the order of
+// confirmation, moves its tokens and joins the history of its sender and
+// recipient.
+func (ws *WalletStore) RestoreWallets(wallets []*entity.Wallet, transactions []entity.Transaction) error {
+	ws.Mu.Lock()
+	defer ws.Mu.Unlock()
+
+	ws.Wallets = make(map[string]*entity.Wallet, len(wallets))
+	for _, wallet := range wallets {
+		ws.Wallets[wallet.Address] = wallet
+	}
+	walletOf := func(address string) *entity.Wallet {
+		wallet, exists := ws.Wallets[address]
+		if !exists {
+			wallet = entity.NewWallet(address)
+			ws.Wallets[address] = wallet
+		}
+		return wallet
+	}
+
+	confirmed := make([]entity.Transaction, 0, len(transactions))
+	for _, transaction := range transactions {
+		if transaction.From != "" {
+			walletOf(transaction.From).RaiseSequence(transaction.Sequence)
+		}
+		if transaction.Status == entity.TransactionStatusConfirmed {
+			confirmed = append(confirmed, transaction)
+		}
+	}
+
+	sort.Slice(confirmed, func(i, j int) bool {
+		if confirmed[i].TimestampConfirmed.Equal(confirmed[j].TimestampConfirmed) {
+			return confirmed[i].ID < confirmed[j].ID
+		}
+		return confirmed[i].TimestampConfirmed.Before(confirmed[j].TimestampConfirmed)
+	})
+
+	for i := range confirmed {
+		transaction := &confirmed[i]
+		if transaction.From != "" {
+			total, err := transaction.TotalAmount()
+			if err != nil {
+				return fmt.Errorf("stored transaction %s: %v", transaction.ID, err)
+			}
+			if err := walletOf(transaction.From).DecreaseBalance(total); err != nil {
+				return fmt.Errorf("stored transaction %s: %v", transaction.ID, err)
+			}
+			walletOf(transaction.From).AddTransactionToHistory(transaction)
+		}
+		if transaction.To != "" {
+			if err := walletOf(transaction.To).IncreaseBalance(transaction.Token); err != nil {
+				return fmt.Errorf("stored transaction %s: %v", transaction.ID, err)
+			}
+			if transaction.To != transaction.From {
+				walletOf(transaction.To).AddTransactionToHistory(transaction)
+			}
+		}
+	}
 
 	return nil
 }
@@ -0,0 +1,135 @@
+package pow
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrMaxIterations is returned when no nonce below the iteration limit solves
+// the proof of work.
+var ErrMaxIterations = errors.New("proof of work gave up after the maximum number of iterations")
+
+// chunkSize is the number of consecutive nonces a worker claims at a time.
+const chunkSize = 4096
+
+// Difficulty returns the number of leading zero hex digits the hash of a
+// transaction with data of the given length must have. The base difficulty
+// grows with the logarithm of the data length, the adjustment is added on top
+// of it and the result is never lower than one.
+func Difficulty(dataLength int, adjustment int) int {
+	difficulty := int(math.Max(1, math.Log2(float64(dataLength/10)))) + 1 + adjustment
+	if difficulty < 1 {
+		difficulty = 1
+	}
+	return difficulty
+}
+
+// Hash returns the hex encoded SHA-256 hash of the preimage followed by the
+// decimal nonce.
+func Hash(preimage []byte, nonce uint64) string {
+	hash := sha256.New()
+	hash.Write(preimage)
+	hash.Write([]byte(strconv.FormatUint(nonce, 10)))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Verify checks that the nonce solves the proof of work of the preimage.
+func Verify(preimage []byte, nonce uint64, difficulty int) bool {
+	return meetsDifficulty(Hash(preimage, nonce), difficulty)
+}
+
+// Result describes a finished mining run.
+type Result struct {
+	Nonce    uint64
+	Attempts uint64
+	Elapsed  time.Duration
+}
+
+// HashRate returns the number of hashes computed per second.
+func (r Result) HashRate() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Attempts) / r.Elapsed.Seconds()
+}
+
+// Mine searches a nonce that solves the proof of work of the preimage with the
+// given number of worker goroutines, which claim chunks of consecutive nonces.
+// It stops when the context is done or when maxIterations nonces were tried,
+// zero meaning no limit. The result is filled in on failure too.
+func Mine(ctx context.Context, preimage []byte, difficulty int, workers int, maxIterations uint64) (Result, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	miningCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+	var next, attempts atomic.Uint64
+	found := make(chan uint64, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for miningCtx.Err() == nil {
+				start := next.Add(chunkSize) - chunkSize
+				end := start + chunkSize
+				if maxIterations > 0 {
+					if start >= maxIterations {
+						return
+					}
+					if end > maxIterations {
+						end = maxIterations
+					}
+				}
+
+				for nonce := start; nonce < end; nonce++ {
+					if Verify(preimage, nonce, difficulty) {
+						attempts.Add(nonce - start + 1)
+						found <- nonce
+						cancel()
+						return
+					}
+				}
+				attempts.Add(end - start)
+			}
+		}()
+	}
+	wg.Wait()
+	close(found)
+
+	result := Result{Attempts: attempts.Load(), Elapsed: time.Since(startTime)}
+
+	// Several workers can find a nonce at the same time, keep the lowest
+	solved := false
+	for nonce := range found {
+		if !solved || nonce < result.Nonce {
+			result.Nonce = nonce
+		}
+		solved = true
+	}
+	if solved {
+		return result, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return result, fmt.Errorf("proof of work cancelled: %w", err)
+	}
+	return result, ErrMaxIterations
+}
+
+func meetsDifficulty(hash string, difficulty int) bool {
+	return difficulty <= len(hash) && strings.Count(hash[:difficulty], "0") == difficulty
+}
@@ -0,0 +1,97 @@
+package pow
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+var testPreimage = []byte("preimage")
+
+func TestMineAndVerify(t *testing.T) {
+	tests := []struct {
+		difficulty int
+		workers    int
+	}{
+		{difficulty: 1, workers: 1},
+		{difficulty: 2, workers: 1},
+		{difficulty: 3, workers: 4},
+		{difficulty: 2, workers: 0},
+	}
+
+	for _, test := range tests {
+		result, err := Mine(context.Background(), testPreimage, test.difficulty, test.workers, 0)
+		if err != nil {
+			t.Errorf("Mine(difficulty %d, %d workers): %v", test.difficulty, test.workers, err)
+			continue
+		}
+		if !Verify(testPreimage, result.Nonce, test.difficulty) {
+			t.Errorf("Mine(difficulty %d, %d workers) = nonce %d, which does not verify", test.difficulty, test.workers, result.Nonce)
+		}
+		if result.Attempts == 0 {
+			t.Errorf("Mine(difficulty %d, %d workers) counted no attempts", test.difficulty, test.workers)
+		}
+	}
+}
+
+func TestMineFindsTheLowestNonce(t *testing.T) {
+	result, err := Mine(context.Background(), testPreimage, 2, 1, 0)
+	if err != nil {
+		t.Fatalf("Mine: %v", err)
+	}
+	for nonce := uint64(0); nonce < result.Nonce; nonce++ {
+		if Verify(testPreimage, nonce, 2) {
+			t.Fatalf("Mine = nonce %d, but %d solves it too", result.Nonce, nonce)
+		}
+	}
+}
+
+func TestMineStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Mine(ctx, testPreimage, 64, 2, 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Mine error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestMineGivesUpAfterMaxIterations(t *testing.T) {
+	tests := []struct {
+		workers       int
+		maxIterations uint64
+	}{
+		{workers: 1, maxIterations: 1000},
+		{workers: 3, maxIterations: 2*chunkSize + 1},
+	}
+
+	for _, test := range tests {
+		result, err := Mine(context.Background(), testPreimage, 64, test.workers, test.maxIterations)
+		if !errors.Is(err, ErrMaxIterations) {
+			t.Errorf("Mine(%d workers, %d iterations) error = %v, want %v", test.workers, test.maxIterations, err, ErrMaxIterations)
+		}
+		if result.Attempts != test.maxIterations {
+			t.Errorf("Mine(%d workers, %d iterations) made %d attempts", test.workers, test.maxIterations, result.Attempts)
+		}
+	}
+}
+
+func TestDifficulty(t *testing.T) {
+	tests := []struct {
+		dataLength int
+		adjustment int
+		want       int
+	}{
+		{dataLength: 0, adjustment: 0, want: 2},
+		{dataLength: 40, adjustment: 0, want: 3},
+		{dataLength: 1280, adjustment: 0, want: 8},
+		{dataLength: 40, adjustment: 2, want: 5},
+		{dataLength: 40, adjustment: -10, want: 1},
+	}
+
+	for _, test := range tests {
+		if got := Difficulty(test.dataLength, test.adjustment); got != test.want {
+			t.Errorf("Difficulty(%d, %d) = %d, want %d", test.dataLength, test.adjustment, got, test.want)
+		}
+	}
+}
@@ -3,12 +3,22 @@ package pubsub
 import (
 	"fmt"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"log"
 )
 
 type CustomNotifee struct{}
 
 func (n *CustomNotifee) HandlePeerFound(info peer.AddrInfo) {
 	fmt.Printf("Found a new peer: %s\n", info.ID.String())
+
+	if info.ID == hostEntity.ID() || peers.isRefused(info.ID) {
+		return
+	}
+
+	// The node is told about the peer once its genesis is verified
+	if err := hostEntity.Connect(ctx, info); err != nil {
+		log.Printf("Error connecting to peer %s: %v", info.ID, err)
+	}
 }
 
 func (n *CustomNotifee) HandlePeerLost(info peer.AddrInfo) {
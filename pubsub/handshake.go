@@ -0,0 +1,156 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"log"
+	"sync"
+	"time"
+)
+
+// Nodes only peer with nodes of the same network. When a connection opens, the
+// node asks the peer for its info over the sync protocol and compares their
+// genesis hashes. A peer with another genesis is disconnected and refused from
+// then on. Until a peer is verified its gossip is dropped and only its info
+// requests are answered.
+
+// handshakeTimeout bounds the info request that verifies a peer.
+const handshakeTimeout = 10 * time.Second
+
+type PeerRegistry struct {
+	verified map[peer.ID]bool
+	refused  map[peer.ID]bool
+	checking map[peer.ID]bool // Peers whose connection check is running
+	mu       sync.Mutex
+}
+
+var peers = &PeerRegistry{
+	verified: make(map[peer.ID]bool),
+	refused:  make(map[peer.ID]bool),
+	checking: make(map[peer.ID]bool),
+}
+
+func (r *PeerRegistry) isVerified(id peer.ID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.verified[id]
+}
+
+func (r *PeerRegistry) isRefused(id peer.ID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.refused[id]
+}
+
+// markVerified reports whether the peer was not verified before.
+func (r *PeerRegistry) markVerified(id peer.ID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.verified[id] {
+		return false
+	}
+	r.verified[id] = true
+	return true
+}
+
+// startCheck reports whether no other check of the peer is running.
+func (r *PeerRegistry) startCheck(id peer.ID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.checking[id] {
+		return false
+	}
+	r.checking[id] = true
+	return true
+}
+
+func (r *PeerRegistry) endCheck(id peer.ID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.checking, id)
+}
+
+func (r *PeerRegistry) refuse(id peer.ID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.verified, id)
+	r.refused[id] = true
+}
+
+// forget reports whether the peer was verified.
+func (r *PeerRegistry) forget(id peer.ID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wasVerified := r.verified[id]
+	delete(r.verified, id)
+	return wasVerified
+}
+
+// watchConnections verifies every peer that connects, in either direction.
+func watchConnections() {
+	hostEntity.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(_ network.Network, conn network.Conn) {
+			remotePeer := conn.RemotePeer()
+			if peers.isVerified(remotePeer) || !peers.startCheck(remotePeer) {
+				return
+			}
+
+			// Notifications must not block, the peer is checked aside
+			go func() {
+				defer peers.endCheck(remotePeer)
+
+				if peers.isRefused(remotePeer) {
+					hostEntity.Network().ClosePeer(remotePeer)
+					return
+				}
+				if err := verifyPeer(remotePeer); err != nil {
+					log.Printf("Disconnecting peer %s: %v", remotePeer, err)
+					hostEntity.Network().ClosePeer(remotePeer)
+				}
+			}()
+		},
+		DisconnectedF: func(n network.Network, conn network.Conn) {
+			if n.Connectedness(conn.RemotePeer()) != network.Connected && peers.forget(conn.RemotePeer()) {
+				PubsubInputInterface.PeerLost(pubsubInput, conn.RemotePeer().String())
+			}
+		},
+	})
+}
+
+// verifyPeer checks that a peer shares the genesis of the node and refuses it
+// otherwise. The node is told about the peer the first time it is verified,
+// and again when it disconnects.
+func verifyPeer(id peer.ID) error {
+	if peers.isRefused(id) {
+		return fmt.Errorf("peer %s was refused", id)
+	}
+
+	requestCtx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	defer cancel()
+
+	info, err := requestInfo(requestCtx, id.String())
+	if err != nil {
+		return fmt.Errorf("requesting peer info: %w", err)
+	}
+
+	localInfo := PubsubInputInterface.InfoRequest(pubsubInput)
+	if info.GenesisHash != localInfo.GenesisHash {
+		peers.refuse(id)
+		return fmt.Errorf("peer genesis %s differs from %s", info.GenesisHash, localInfo.GenesisHash)
+	}
+
+	if peers.markVerified(id) {
+		log.Printf("Verified genesis of peer %s", id)
+		PubsubInputInterface.PeerFound(pubsubInput, id.String())
+	}
+	return nil
+}
+
+// acceptVerifiedSender drops the gossip forwarded by peers whose genesis was
+// not verified.
+func acceptVerifiedSender(_ context.Context, sender peer.ID, _ *pubsub.Message) bool {
+	return sender == hostEntity.ID() || peers.isVerified(sender)
+}
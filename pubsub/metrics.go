@@ -0,0 +1,20 @@
+package pubsub
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Directions of the pubsub messages.
+const (
+	directionIn  string = "in"
+	directionOut string = "out"
+)
+
+var pubsubMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "energy_pubsub_messages_total",
+	Help: "Pubsub messages received from peers or published by the node, by topic and direction.",
+}, []string{"topic", "direction"})
+
+func initMetrics() {
+	prometheus.MustRegister(pubsubMessages)
+}
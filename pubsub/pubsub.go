@@ -3,29 +3,41 @@ package pubsub
 import (
 	"context"
 	"encoding/json"
+	"energy/config"
 	"energy/domain/entity"
+	"errors"
 	"fmt"
 	"github.com/libp2p/go-libp2p"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/host"
-	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
 	"log"
+	"sync"
 	"time"
 )
 
 var pubsubClient *pubsub.PubSub
 var walletCreateTopic *pubsub.Topic
 var newTransactionTopic *pubsub.Topic
+var subscriptions []*pubsub.Subscription
 
 var hostEntity host.Host
+var kademliaDHT *dht.IpfsDHT
+var mdnsService mdns.Service
 
+// ctx lives from StartPubsub to StopPubsub, cancel ends it
 var ctx = context.Background()
+var cancel context.CancelFunc = func() {}
+
+// workers tracks the goroutines started by the pubsub
+var workers sync.WaitGroup
 
 var pubsubInput PubsubInputInterface
 
+var pubsubConfig config.PubsubConfig
+
 type PubSubDI struct {
 	PubsubOutput PubsubOutputInterface
 }
@@ -36,56 +48,125 @@ func NewPubSubDI(pubsubOutput PubsubOutputInterface) *PubSubDI {
 	}
 }
 
-func StartPubsub(pubsubInputInterface PubsubInputInterface) {
+// StartPubsub starts the libp2p host, peer discovery and the topic
+// subscriptions. They run until StopPubsub is called or the context is done.
+func StartPubsub(parentCtx context.Context, pubsubInputInterface PubsubInputInterface, pubsubCfg config.PubsubConfig) error {
 	log.Println("Initializing pubsub...")
 
 	pubsubInput = pubsubInputInterface
+	pubsubConfig = pubsubCfg
+
+	ctx, cancel = context.WithCancel(parentCtx)
+
+	initMetrics()
+
+	if err := initInternalPubSub(); err != nil {
+		return err
+	}
+
+	return initTopics()
+}
+
+// StopPubsub cancels the subscriptions, closes the topics, peer discovery and
+// the libp2p host, and waits for the pubsub goroutines until the context is
+// done.
+func StopPubsub(stopCtx context.Context) error {
+	log.Println("Stopping pubsub...")
+
+	for _, subscription := range subscriptions {
+		subscription.Cancel()
+	}
+
+	// Gossipsub already released the topics if the context of StartPubsub is
+	// done, otherwise they are closed while it is still running
+	var errs []error
+	if ctx.Err() == nil {
+		for _, topic := range []*pubsub.Topic{walletCreateTopic, newTransactionTopic} {
+			if topic != nil {
+				errs = append(errs, topic.Close())
+			}
+		}
+	}
 
-	initInternalPubSub()
+	cancel()
+	if mdnsService != nil {
+		errs = append(errs, mdnsService.Close())
+	}
+	if kademliaDHT != nil {
+		errs = append(errs, kademliaDHT.Close())
+	}
+	if hostEntity != nil {
+		errs = append(errs, hostEntity.Close())
+	}
 
-	initTopics()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-stopCtx.Done():
+		errs = append(errs, fmt.Errorf("waiting for pubsub goroutines: %w", stopCtx.Err()))
+	}
+
+	return errors.Join(errs...)
 }
 
-func initInternalPubSub() {
+func initInternalPubSub() error {
 	// Init host
 	var errHost error
 	hostEntity, errHost = libp2p.New()
 	if errHost != nil {
-		log.Fatal(errHost)
+		return errHost
 	}
-	defer hostEntity.Close()
 
 	log.Printf("Host ID is %s\n", hostEntity.ID())
 
+	watchConnections()
+
 	// Set up a DHT (Distributed Hash Table) for peer discovery
-	kademliaDHT, errDHT := dht.New(ctx, hostEntity)
+	var errDHT error
+	kademliaDHT, errDHT = dht.New(ctx, hostEntity)
 	if errDHT != nil {
-		log.Fatal(errDHT)
+		return errDHT
 	}
 	errDHT = kademliaDHT.Bootstrap(ctx)
 	if errDHT != nil {
-		log.Fatal(errDHT)
+		return errDHT
 	}
 
 	// Init MDNS for peer discovery
 	notifee := &CustomNotifee{}
-	mdnsService := mdns.NewMdnsService(hostEntity, "Energy", notifee)
-	defer mdnsService.Close()
+	mdnsService = mdns.NewMdnsService(hostEntity, pubsubConfig.MdnsServiceTag, notifee)
+	if errMdns := mdnsService.Start(); errMdns != nil {
+		log.Println("Error starting MDNS discovery:", errMdns)
+	}
 
 	// Create a Gossipsub instance for pub-sub communication
 	var errPubsub error
 	pubsubClient, errPubsub = pubsub.NewGossipSub(ctx, hostEntity)
 	if errPubsub != nil {
-		log.Fatal(errPubsub)
+		return errPubsub
+	}
+
+	hostEntity.SetStreamHandler(syncProtocolID, handleSyncStream)
+
+	if pubsubConfig.PeerLogInterval > 0 {
+		logPeers()
 	}
 
-	hostEntity.SetStreamHandler("/energy", func(stream network.Stream) {
-		fmt.Fprintln(stream, "Hello, libp2p!")
-		stream.Close()
-	})
+	return nil
+}
 
+// logPeers prints the connected peers periodically until the pubsub stops.
+func logPeers() {
+	workers.Add(1)
 	go func() {
-		ticker := time.NewTicker(30 * time.Second)
+		defer workers.Done()
+
+		ticker := time.NewTicker(pubsubConfig.PeerLogInterval)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
@@ -101,73 +182,79 @@ func initInternalPubSub() {
 	}()
 }
 
-func initTopics() {
+func initTopics() error {
 	log.Println("Initializing pubsub topics...")
 
+	for _, topic := range []string{pubsubConfig.WalletCreateTopic, pubsubConfig.NewTransactionTopic} {
+		if err := pubsubClient.RegisterTopicValidator(topic, acceptVerifiedSender); err != nil {
+			return err
+		}
+	}
+
 	var errTopic error
-	walletCreateTopic, errTopic = pubsubClient.Join("wallet-create-topic")
+	walletCreateTopic, errTopic = pubsubClient.Join(pubsubConfig.WalletCreateTopic)
 	if errTopic != nil {
-		log.Fatal(errTopic)
+		return errTopic
 	}
 
 	subscriber, errSubscriber := walletCreateTopic.Subscribe()
 	if errSubscriber != nil {
-		panic(errSubscriber)
+		return errSubscriber
 	}
 	subscribe(subscriber, hostEntity.ID())
 
-	newTransactionTopic, errTopic = pubsubClient.Join("new-transaction-topic")
+	newTransactionTopic, errTopic = pubsubClient.Join(pubsubConfig.NewTransactionTopic)
 	if errTopic != nil {
-		log.Fatal(errTopic)
+		return errTopic
 	}
 
 	subscriber, errSubscriber = newTransactionTopic.Subscribe()
 	if errSubscriber != nil {
-		panic(errSubscriber)
+		return errSubscriber
 	}
 	subscribe(subscriber, hostEntity.ID())
+
+	return nil
 }
 
 func subscribe(subscriber *pubsub.Subscription, hostID peer.ID) {
-	goroutineCtx, cancel := context.WithCancel(context.Background())
+	subscriptions = append(subscriptions, subscriber)
 
+	workers.Add(1)
 	go func() {
-		defer cancel()
+		defer workers.Done()
 
 		for {
-			select {
-			case <-goroutineCtx.Done():
-				log.Println("Goroutine context canceled, stopping subscription.")
-				return
-			default:
-				msg, err := subscriber.Next(goroutineCtx)
-				if err != nil {
-					panic(err)
+			msg, err := subscriber.Next(ctx)
+			if err != nil {
+				if ctx.Err() == nil && !errors.Is(err, pubsub.ErrSubscriptionCancelled) {
+					log.Println("Error reading subscription:", err)
 				}
+				return
+			}
 
-				// only consider messages delivered by other peers
-				if msg.ReceivedFrom == hostID {
-					continue
-				}
+			// only consider messages delivered by other peers
+			if msg.ReceivedFrom == hostID {
+				continue
+			}
+			pubsubMessages.WithLabelValues(subscriber.Topic(), directionIn).Inc()
 
-				var pubsubMessage entity.PubsubMessage
+			var pubsubMessage entity.PubsubMessage
 
-				if err := json.Unmarshal(msg.Data, &pubsubMessage); err != nil {
-					log.Println("Error unmarshaling message:", err)
-					continue
-				}
+			if err := json.Unmarshal(msg.Data, &pubsubMessage); err != nil {
+				log.Println("Error unmarshaling message:", err)
+				continue
+			}
 
-				switch pubsubMessage.Type {
-				case entity.PubSubWalletCreate:
-					PubsubInputInterface.WalletCreateMessage(pubsubInput, pubsubMessage)
-				case entity.PubSubNewTransaction:
-					PubsubInputInterface.NewTransactionMessage(pubsubInput, pubsubMessage)
-				default:
-					log.Printf("Unknown message type: %s", pubsubMessage.Type)
-				}
+			switch pubsubMessage.Type {
+			case entity.PubSubWalletCreate:
+				PubsubInputInterface.WalletCreateMessage(pubsubInput, pubsubMessage)
+			case entity.PubSubNewTransaction:
+				PubsubInputInterface.NewTransactionMessage(pubsubInput, pubsubMessage)
+			default:
+				log.Printf("Unknown message type: %s", pubsubMessage.Type)
 			}
 		}
-
 	}()
 }
 
@@ -180,16 +267,20 @@ func (e PubsubOutputImpl) BroadcastMessage(otherContext context.Context, message
 		log.Println("Error marshaling PubsubMessage:", err)
 		return
 	}
+	var topic *pubsub.Topic
 	switch message.Type {
 	case entity.PubSubWalletCreate:
-		err = walletCreateTopic.Publish(otherContext, messageBytes)
-		if err != nil {
-			log.Println("Error publishing message:", err)
-		}
+		topic = walletCreateTopic
 	case entity.PubSubNewTransaction:
-		err = newTransactionTopic.Publish(otherContext, messageBytes)
-		if err != nil {
-			log.Println("Error publishing message:", err)
-		}
+		topic = newTransactionTopic
+	default:
+		return
+	}
+
+	err = topic.Publish(otherContext, messageBytes)
+	if err != nil {
+		log.Println("Error publishing message:", err)
+		return
 	}
+	pubsubMessages.WithLabelValues(topic.String(), directionOut).Inc()
 }
@@ -7,4 +7,9 @@ import (
 type PubsubInputInterface interface {
 	WalletCreateMessage(message entity.PubsubMessage)
 	NewTransactionMessage(message entity.PubsubMessage)
+	PeerFound(peerID string)
+	PeerLost(peerID string)
+	TipsRequest() []string
+	TransactionsRequest(ids []string) []entity.Transaction
+	InfoRequest() entity.NodeInfo
 }
@@ -7,4 +7,8 @@ import (
 
 type PubsubOutputInterface interface {
 	BroadcastMessage(otherContext context.Context, message entity.PubsubMessage)
+	RequestTips(otherContext context.Context, peerID string) ([]string, error)
+	RequestTransactions(otherContext context.Context, peerID string, ids []string) ([]entity.Transaction, error)
+	RequestInfo(otherContext context.Context, peerID string) (entity.NodeInfo, error)
+	Peers() []string
 }
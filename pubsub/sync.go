@@ -0,0 +1,152 @@
+package pubsub
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"energy/domain/entity"
+	"errors"
+	"fmt"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"log"
+	"time"
+)
+
+// The sync protocol runs over the "/energy" stream. The requesting node writes a
+// single JSON encoded SyncRequest and closes its side of the stream, the peer
+// answers with a single JSON encoded SyncResponse.
+
+const syncProtocolID = "/energy"
+
+const syncTimeout = 30 * time.Second
+
+// maxSyncTransactions limits the number of transactions served per request.
+const maxSyncTransactions = 500
+
+func handleSyncStream(stream network.Stream) {
+	defer stream.Close()
+
+	stream.SetDeadline(time.Now().Add(syncTimeout))
+
+	var request entity.SyncRequest
+	if err := json.NewDecoder(bufio.NewReader(stream)).Decode(&request); err != nil {
+		log.Println("Error decoding sync request:", err)
+		stream.Reset()
+		return
+	}
+
+	var response entity.SyncResponse
+
+	// Only the info request is answered before the genesis of the peer is
+	// checked, which happens now if the check of the connection is late
+	remotePeer := stream.Conn().RemotePeer()
+	if request.Type != entity.SyncInfo && !peers.isVerified(remotePeer) {
+		if err := verifyPeer(remotePeer); err != nil {
+			response.Error = err.Error()
+			writeSyncResponse(stream, response)
+			return
+		}
+	}
+
+	switch request.Type {
+	case entity.SyncTips:
+		response.Tips = PubsubInputInterface.TipsRequest(pubsubInput)
+	case entity.SyncTransactions:
+		if len(request.IDs) > maxSyncTransactions {
+			request.IDs = request.IDs[:maxSyncTransactions]
+		}
+		response.Transactions = PubsubInputInterface.TransactionsRequest(pubsubInput, request.IDs)
+	case entity.SyncInfo:
+		info := PubsubInputInterface.InfoRequest(pubsubInput)
+		response.Info = &info
+	default:
+		response.Error = fmt.Sprintf("unknown sync request type: %s", request.Type)
+	}
+
+	writeSyncResponse(stream, response)
+}
+
+func writeSyncResponse(stream network.Stream, response entity.SyncResponse) {
+	if err := json.NewEncoder(stream).Encode(response); err != nil {
+		log.Println("Error encoding sync response:", err)
+		stream.Reset()
+	}
+}
+
+func sendSyncRequest(otherContext context.Context, peerID string, request entity.SyncRequest) (entity.SyncResponse, error) {
+	var response entity.SyncResponse
+
+	id, err := peer.Decode(peerID)
+	if err != nil {
+		return response, fmt.Errorf("invalid peer ID %s: %v", peerID, err)
+	}
+
+	requestContext, cancel := context.WithTimeout(otherContext, syncTimeout)
+	defer cancel()
+
+	stream, err := hostEntity.NewStream(requestContext, id, syncProtocolID)
+	if err != nil {
+		return response, fmt.Errorf("error opening sync stream: %v", err)
+	}
+	defer stream.Close()
+
+	if deadline, ok := requestContext.Deadline(); ok {
+		stream.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(stream).Encode(request); err != nil {
+		stream.Reset()
+		return response, fmt.Errorf("error sending sync request: %v", err)
+	}
+	stream.CloseWrite()
+
+	if err := json.NewDecoder(bufio.NewReader(stream)).Decode(&response); err != nil {
+		stream.Reset()
+		return response, fmt.Errorf("error reading sync response: %v", err)
+	}
+
+	if response.Error != "" {
+		return response, errors.New(response.Error)
+	}
+
+	return response, nil
+}
+
+func (e PubsubOutputImpl) RequestTips(otherContext context.Context, peerID string) ([]string, error) {
+	response, err := sendSyncRequest(otherContext, peerID, entity.SyncRequest{Type: entity.SyncTips})
+	return response.Tips, err
+}
+
+func (e PubsubOutputImpl) RequestTransactions(otherContext context.Context, peerID string, ids []string) ([]entity.Transaction, error) {
+	response, err := sendSyncRequest(otherContext, peerID, entity.SyncRequest{Type: entity.SyncTransactions, IDs: ids})
+	return response.Transactions, err
+}
+
+func (e PubsubOutputImpl) RequestInfo(otherContext context.Context, peerID string) (entity.NodeInfo, error) {
+	return requestInfo(otherContext, peerID)
+}
+
+func requestInfo(otherContext context.Context, peerID string) (entity.NodeInfo, error) {
+	response, err := sendSyncRequest(otherContext, peerID, entity.SyncRequest{Type: entity.SyncInfo})
+	if err != nil {
+		return entity.NodeInfo{}, err
+	}
+	if response.Info == nil {
+		return entity.NodeInfo{}, errors.New("empty info response")
+	}
+	return *response.Info, nil
+}
+
+func (e PubsubOutputImpl) Peers() []string {
+	if hostEntity == nil {
+		return nil
+	}
+	peers := hostEntity.Network().Peers()
+
+	peerIDs := make([]string, 0, len(peers))
+	for _, p := range peers {
+		peerIDs = append(peerIDs, p.String())
+	}
+	return peerIDs
+}
@@ -0,0 +1,25 @@
+package wallet
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The wallet exposes Prometheus metrics on /metrics of its API, registered
+// when the wallet starts.
+
+var (
+	powSolveTime = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "energy_wallet_pow_solve_seconds",
+		Help:    "Time the wallet took to find the proof of work of a transaction.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 16),
+	})
+
+	powFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "energy_wallet_pow_failures_total",
+		Help: "Proofs of work the wallet gave up, cancelled or over the iteration limit.",
+	})
+)
+
+func initMetrics() {
+	prometheus.MustRegister(powSolveTime, powFailures)
+}
@@ -0,0 +1,354 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"energy/config"
+	"energy/domain/entity/response"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The wallet keeps a pool of the nodes it talks to: the configured ones and
+// the ones their peers advertise. A health check measures the latency of every
+// node periodically and each request tries the healthy nodes first, in random
+// order or the fastest first. A request that fails because of its node is
+// retried on the next one.
+
+const (
+	NodeSelectionRandom  string = "random"
+	NodeSelectionLatency string = "latency"
+)
+
+// healthCheckTimeout bounds a health check and a peer list request.
+const healthCheckTimeout = 2 * time.Second
+
+// maxNodeFailures is the number of failures in a row after which a discovered
+// node leaves the pool. Configured nodes are never removed.
+const maxNodeFailures = 3
+
+// nodeClient bounds every request to a node.
+var nodeClient = &http.Client{Timeout: 30 * time.Second}
+
+type poolNode struct {
+	url        string
+	configured bool
+	healthy    bool
+	latency    time.Duration
+	failures   int
+}
+
+type NodePool struct {
+	nodes     map[string]*poolNode
+	selection string
+	// Number of nodes a request is tried on
+	attempts int
+	mu       sync.Mutex
+}
+
+var nodePool *NodePool
+
+// NewNodePool returns a pool of the configured nodes, which are deemed healthy
+// until a health check or a request fails.
+func NewNodePool(urls []string, selection string, retries int) (*NodePool, error) {
+	if selection != NodeSelectionRandom && selection != NodeSelectionLatency {
+		return nil, fmt.Errorf("unknown node selection %q, expected random or latency", selection)
+	}
+	if retries < 0 {
+		return nil, errors.New("node retries cannot be negative")
+	}
+
+	pool := &NodePool{
+		nodes:     make(map[string]*poolNode),
+		selection: selection,
+		attempts:  retries + 1,
+	}
+	for _, nodeURL := range urls {
+		pool.nodes[nodeURL] = &poolNode{url: nodeURL, configured: true, healthy: true}
+	}
+	return pool, nil
+}
+
+// Candidates returns the node URLs in the order a request tries them. The
+// nodes that failed come last, in case every health check is stale.
+func (p *NodePool) Candidates() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var healthy, unhealthy []*poolNode
+	for _, node := range p.nodes {
+		if node.healthy {
+			healthy = append(healthy, node)
+		} else {
+			unhealthy = append(unhealthy, node)
+		}
+	}
+
+	mathrand.Shuffle(len(healthy), func(i, j int) { healthy[i], healthy[j] = healthy[j], healthy[i] })
+	if p.selection == NodeSelectionLatency {
+		sort.SliceStable(healthy, func(i, j int) bool { return healthy[i].latency < healthy[j].latency })
+	}
+	sort.Slice(unhealthy, func(i, j int) bool { return unhealthy[i].failures < unhealthy[j].failures })
+
+	urls := make([]string, 0, len(p.nodes))
+	for _, node := range append(healthy, unhealthy...) {
+		urls = append(urls, node.url)
+	}
+	return urls
+}
+
+// Do calls attempt with one node after the other until it succeeds, fails
+// for a reason other than its node or the retries run out. The preferred
+// node, if any, is tried first.
+func (p *NodePool) Do(ctx context.Context, preferred string, attempt func(nodeURL string) error) error {
+	candidates := p.Candidates()
+	if preferred != "" {
+		candidates = slices.DeleteFunc(candidates, func(nodeURL string) bool { return nodeURL == preferred })
+		candidates = append([]string{preferred}, candidates...)
+	}
+	if len(candidates) > p.attempts {
+		candidates = candidates[:p.attempts]
+	}
+
+	err := errors.New("no node available")
+	for _, nodeURL := range candidates {
+		err = attempt(nodeURL)
+
+		if err == nil {
+			p.markHealthy(nodeURL, 0)
+			return nil
+		}
+		var unavailable *nodeUnavailableError
+		if !errors.As(err, &unavailable) {
+			return err
+		}
+		log.Println("Node failed:", err)
+		p.markFailed(nodeURL)
+
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (p *NodePool) add(nodeURL string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.nodes[nodeURL]; !exists {
+		log.Println("Discovered node", nodeURL)
+		p.nodes[nodeURL] = &poolNode{url: nodeURL}
+	}
+}
+
+// markHealthy records that a node answered, keeping its latency when the
+// given one is zero.
+func (p *NodePool) markHealthy(nodeURL string, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if node, exists := p.nodes[nodeURL]; exists {
+		node.healthy = true
+		node.failures = 0
+		if latency > 0 {
+			node.latency = latency
+		}
+	}
+}
+
+func (p *NodePool) markFailed(nodeURL string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	node, exists := p.nodes[nodeURL]
+	if !exists {
+		return
+	}
+	node.healthy = false
+	node.failures++
+	if !node.configured && node.failures >= maxNodeFailures {
+		log.Println("Removing unreachable node", nodeURL)
+		delete(p.nodes, nodeURL)
+	}
+}
+
+func (p *NodePool) urls() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	urls := make([]string, 0, len(p.nodes))
+	for nodeURL := range p.nodes {
+		urls = append(urls, nodeURL)
+	}
+	return urls
+}
+
+// Status describes the nodes of the pool.
+func (p *NodePool) Status() []gin.H {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := make([]gin.H, 0, len(p.nodes))
+	for _, node := range p.nodes {
+		status = append(status, gin.H{
+			"url":        node.url,
+			"configured": node.configured,
+			"healthy":    node.healthy,
+			"latencyMs":  node.latency.Milliseconds(),
+		})
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i]["url"].(string) < status[j]["url"].(string) })
+	return status
+}
+
+// run checks the health of the nodes until the context is done.
+func (p *NodePool) run(ctx context.Context, interval time.Duration, discovery bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.checkHealth(ctx, discovery)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkHealth measures the latency of every node and, with discovery, adds
+// the nodes advertised by the healthy ones.
+func (p *NodePool) checkHealth(ctx context.Context, discovery bool) {
+	var wg sync.WaitGroup
+	var discoveredMu sync.Mutex
+	var discovered []string
+
+	for _, nodeURL := range p.urls() {
+		wg.Add(1)
+		go func(nodeURL string) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			if err := callNode(checkCtx, http.MethodGet, nodeURL, "/node/health", nil, nil); err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Health check of node %s failed: %v", nodeURL, err)
+					p.markFailed(nodeURL)
+				}
+				return
+			}
+			p.markHealthy(nodeURL, time.Since(start))
+
+			if !discovery {
+				return
+			}
+			var peersResponse response.PeersResponse
+			if err := callNode(checkCtx, http.MethodGet, nodeURL, "/node/peers", nil, &peersResponse); err != nil {
+				log.Printf("Error listing the peers of node %s: %v", nodeURL, err)
+				return
+			}
+			discoveredMu.Lock()
+			discovered = append(discovered, peersResponse.Peers...)
+			discoveredMu.Unlock()
+		}(nodeURL)
+	}
+	wg.Wait()
+
+	for _, peerURL := range discovered {
+		normalized, err := config.NormalizeNodeURL(peerURL)
+		if err != nil {
+			log.Println("Ignoring advertised node:", err)
+			continue
+		}
+		p.add(normalized)
+	}
+}
+
+// nodeUnavailableError is a failure caused by the node rather than by the
+// request, which is worth retrying on another node.
+type nodeUnavailableError struct {
+	node string
+	err  error
+}
+
+func (e *nodeUnavailableError) Error() string {
+	return fmt.Sprintf("node %s unavailable: %v", e.node, e.err)
+}
+
+func (e *nodeUnavailableError) Unwrap() error {
+	return e.err
+}
+
+// nodeRejectedError is an answer of a node with a client error status.
+type nodeRejectedError struct {
+	status int
+	body   string
+}
+
+func (e *nodeRejectedError) Error() string {
+	return fmt.Sprintf("node answered with status %d: %s", e.status, e.body)
+}
+
+// callNode sends a request with an optional JSON body to a node API and
+// decodes its JSON answer into result, if not nil.
+func callNode(ctx context.Context, method string, nodeURL string, path string, body any, result any) error {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyJSON, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		bodyReader = bytes.NewReader(bodyJSON)
+	}
+
+	nodeRequest, err := http.NewRequestWithContext(ctx, method, nodeURL+path, bodyReader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		nodeRequest.Header.Set("Content-Type", "application/json")
+	}
+
+	nodeResponse, err := nodeClient.Do(nodeRequest)
+	if err != nil {
+		// The node is not to blame when the caller gave up
+		if ctx.Err() != nil {
+			return err
+		}
+		return &nodeUnavailableError{node: nodeURL, err: err}
+	}
+	defer nodeResponse.Body.Close()
+
+	responseBody, err := io.ReadAll(nodeResponse.Body)
+	if err != nil {
+		return &nodeUnavailableError{node: nodeURL, err: err}
+	}
+
+	if nodeResponse.StatusCode >= http.StatusInternalServerError {
+		return &nodeUnavailableError{node: nodeURL, err: fmt.Errorf("status %d: %s", nodeResponse.StatusCode, responseBody)}
+	}
+	if nodeResponse.StatusCode != http.StatusOK {
+		return &nodeRejectedError{status: nodeResponse.StatusCode, body: string(responseBody)}
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(responseBody, result); err != nil {
+			return &nodeUnavailableError{node: nodeURL, err: fmt.Errorf("invalid answer: %v", err)}
+		}
+	}
+	return nil
+}
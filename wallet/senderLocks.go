@@ -0,0 +1,64 @@
+package wallet
+
+import (
+	"context"
+	"sync"
+)
+
+// A transaction carries the sequence of the last accepted transaction of its
+// sender plus one, so two transactions of the same sender built at the same
+// time get the same sequence, and the nodes keep only one of them as a double
+// spend. The wallet builds and submits the transactions of a sender one at a
+// time, from reading the sequence until the node answers. Other wallet
+// services sending from the same address are not coordinated.
+
+type SenderLocks struct {
+	mu    sync.Mutex
+	locks map[string]*senderLock
+}
+
+type senderLock struct {
+	held    chan struct{} // Holds a value while a request of the sender runs
+	waiting int           // Requests holding or waiting for the lock
+}
+
+var senderLocks = NewSenderLocks()
+
+func NewSenderLocks() *SenderLocks {
+	return &SenderLocks{locks: make(map[string]*senderLock)}
+}
+
+// Lock waits until no other request of the sender runs, or until the context
+// is done. The returned function releases the lock.
+func (l *SenderLocks) Lock(ctx context.Context, address string) (func(), error) {
+	l.mu.Lock()
+	lock, exists := l.locks[address]
+	if !exists {
+		lock = &senderLock{held: make(chan struct{}, 1)}
+		l.locks[address] = lock
+	}
+	lock.waiting++
+	l.mu.Unlock()
+
+	select {
+	case lock.held <- struct{}{}:
+		return func() {
+			<-lock.held
+			l.release(address, lock)
+		}, nil
+	case <-ctx.Done():
+		l.release(address, lock)
+		return nil, ctx.Err()
+	}
+}
+
+// release forgets the lock of a sender once no request holds or waits for it.
+func (l *SenderLocks) release(address string, lock *senderLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock.waiting--
+	if lock.waiting == 0 {
+		delete(l.locks, address)
+	}
+}
@@ -1,22 +1,47 @@
 package wallet
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"log"
+	"net"
+	"net/http"
 )
 
-func initWalletAPI(port string) {
+var walletServer *http.Server
+
+func initWalletAPI(port string) error {
 	log.Println("Starting wallet api...")
-	go func() {
 
-		router := gin.Default()
+	router := gin.Default()
+
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	router.GET("/wallet/getNode", getNodeHandler)
+
+	router.GET("/wallet/nodes", getNodesHandler)
 
-		router.GET("/wallet/getNode", getNodeHandler)
+	router.POST("/wallet", postNewWalletHandler)
 
-		router.POST("/wallet", postNewWalletHandler)
+	router.GET("/wallet/:address", getWalletHandler)
 
-		router.POST("/wallet/transaction", postNewTransactionHandler)
+	router.GET("/wallet/:address/history", getWalletHistoryHandler)
 
-		router.Run(":" + port)
+	router.POST("/wallet/transaction", postNewTransactionHandler)
+
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+
+	walletServer = &http.Server{Handler: router}
+
+	go func() {
+		if err := walletServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Println("Error serving wallet api:", err)
+		}
 	}()
+
+	return nil
 }
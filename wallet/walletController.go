@@ -1,54 +1,85 @@
 package wallet
 
 import (
-	"bytes"
+	"context"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"energy/config"
 	"energy/domain/entity"
 	"energy/domain/entity/request"
 	"energy/domain/entity/response"
+	"energy/pow"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"io"
-	"io/ioutil"
 	"log"
-	"math"
 	"net/http"
-	"strings"
-	"time"
 )
 
-var node string
+var walletConfig config.WalletConfig
 
-var nodePort string
+// stopHealthChecks stops the health checks of the node pool
+var stopHealthChecks context.CancelFunc = func() {}
 
-func StartWallet(walletPort string, _nodePort string) {
+func StartWallet(walletCfg config.WalletConfig) error {
 	log.Println("Starting wallet api...")
 
-	nodePort = _nodePort
+	if walletCfg.PowWorkers < 1 {
+		return errors.New("proof of work workers must be at least 1")
+	}
+	if len(walletCfg.NodeURLs) == 0 {
+		return errors.New("the wallet needs at least one node URL")
+	}
+	walletConfig = walletCfg
+
+	initMetrics()
+
+	var err error
+	nodePool, err = NewNodePool(walletCfg.NodeURLs, walletCfg.NodeSelection, walletCfg.NodeRetries)
+	if err != nil {
+		return err
+	}
+	log.Printf("Using %s node selection over %d configured nodes", walletCfg.NodeSelection, len(walletCfg.NodeURLs))
+
+	if walletCfg.NodeHealthInterval > 0 {
+		var healthCtx context.Context
+		healthCtx, stopHealthChecks = context.WithCancel(context.Background())
+		go nodePool.run(healthCtx, walletCfg.NodeHealthInterval, walletCfg.NodeDiscovery)
+	}
 
-	initWalletAPI(walletPort)
+	return initWalletAPI(walletCfg.Port)
+}
+
+// StopWallet stops accepting API requests and waits for the ones in flight
+// until the context is done.
+func StopWallet(ctx context.Context) error {
+	log.Println("Stopping wallet...")
+
+	stopHealthChecks()
+
+	if walletServer == nil {
+		return nil
+	}
+	return walletServer.Shutdown(ctx)
 }
 
 func getNodeHandler(c *gin.Context) {
 	log.Println("Received get node request")
 
-	node = getRandomNode()
+	c.JSON(http.StatusOK, gin.H{
+		"node": nodePool.Candidates()[0],
+	})
+}
 
+func getNodesHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"node": node,
+		"nodes": nodePool.Status(),
 	})
 }
 
 func postNewWalletHandler(c *gin.Context) {
 
-	if node == "" {
-		node = getRandomNode()
-	}
-
 	seed, err := generateMasterSeed()
 	if err != nil {
 		log.Println(err.Error(), err)
@@ -56,35 +87,84 @@ func postNewWalletHandler(c *gin.Context) {
 		return
 	}
 
-	nodeEndpoint := node + "/node/newWallet/" + seed
+	address, err := entity.DeriveAddress(seed)
+	if err != nil {
+		log.Println(err.Error(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	execute, err := http.Post(nodeEndpoint, "application/json", nil)
+	// Only the public address leaves the wallet, the seed is returned to the client
+	ctx := c.Request.Context()
+	err = nodePool.Do(ctx, "", func(nodeURL string) error {
+		return callNode(ctx, http.MethodPost, nodeURL, "/node/newWallet/"+address, nil, nil)
+	})
 	if err != nil {
 		log.Println("Error making request to node endpoint:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error making request to node endpoint"})
+		respondNodeError(c, "Error making request to node endpoint", err)
 		return
 	}
-	defer execute.Body.Close()
 
-	if execute.StatusCode != http.StatusOK {
-		log.Printf("Unexpected status code: %d", execute.StatusCode)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unexpected status code"})
+	c.JSON(http.StatusOK, gin.H{
+		"seed":    seed,
+		"address": address,
+	})
+}
+
+// getWalletHandler passes on the balance, pending outgoing amount and
+// sequence of a wallet from a node of the pool.
+func getWalletHandler(c *gin.Context) {
+	proxyWalletRequest(c, "/node/wallet/"+c.Param("address"))
+}
+
+// getWalletHistoryHandler passes on a page of the history of a wallet from a
+// node of the pool. History cursors are transaction IDs, so the next page can
+// be read from another node.
+func getWalletHistoryHandler(c *gin.Context) {
+	path := "/node/wallet/" + c.Param("address") + "/history"
+	if c.Request.URL.RawQuery != "" {
+		path += "?" + c.Request.URL.RawQuery
+	}
+	proxyWalletRequest(c, path)
+}
+
+func proxyWalletRequest(c *gin.Context, path string) {
+	if !entity.IsValidAddress(c.Param("address")) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid address"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"seed": seed,
+	ctx := c.Request.Context()
+	var answer json.RawMessage
+	err := nodePool.Do(ctx, "", func(nodeURL string) error {
+		return callNode(ctx, http.MethodGet, nodeURL, path, nil, &answer)
 	})
+	if err != nil {
+		log.Println("Error making request to node endpoint:", err)
+		respondNodeError(c, "Error making request to node endpoint", err)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", answer)
 }
 
-func getRandomNode() string {
-	return "http://localhost:" + nodePort
+// respondNodeError answers with the status of a node that rejected the
+// request, or with 502 when no node could handle it.
+func respondNodeError(c *gin.Context, message string, err error) {
+	var rejected *nodeRejectedError
+	if errors.As(err, &rejected) {
+		detail := any(rejected.body)
+		if json.Valid([]byte(rejected.body)) {
+			detail = json.RawMessage(rejected.body)
+		}
+		c.JSON(rejected.status, gin.H{"error": message, "detail": detail})
+		return
+	}
+	c.JSON(http.StatusBadGateway, gin.H{"error": message, "detail": err.Error()})
 }
 
 func generateMasterSeed() (string, error) {
-	seedLength := 64
-
-	randomBytes := make([]byte, seedLength)
+	randomBytes := make([]byte, entity.SeedLength)
 
 	_, err := rand.Read(randomBytes)
 	if err != nil {
@@ -96,34 +176,21 @@ func generateMasterSeed() (string, error) {
 	return randomSeed, nil
 }
 
-func doProofOfWork(transactionData string, maxIterations uint64) (uint64, error) {
-	var nonce uint64
-
-	// Ensure a non-negative repeat count
-	targetPrefixLength := int(math.Max(1, math.Log2(float64(len(transactionData)/10)))) + 1
-	targetPrefix := strings.Repeat("0", targetPrefixLength)
+// doProofOfWork searches a nonce for the transaction until it is found, the
+// context is cancelled or the iteration limit is reached. The nonce is bound
+// to every other field, so the transaction must not change afterwards.
+func doProofOfWork(ctx context.Context, transaction *entity.Transaction, difficultyAdjustment int) (uint64, error) {
+	difficulty := pow.Difficulty(len(transaction.Data), difficultyAdjustment)
 
-	startTime := time.Now()
-	for nonce < maxIterations {
-		attempt := fmt.Sprintf("%s%d", transactionData, nonce)
-
-		hash := sha256.New()
-		hash.Write([]byte(attempt))
-		hashBytes := hash.Sum(nil)
-		hashString := hex.EncodeToString(hashBytes)
-
-		//log.Printf("Attempt: %s, Hash: %s", attempt, hashString)
-
-		if strings.HasPrefix(hashString, targetPrefix) {
-			elapsedTime := time.Since(startTime)
-			log.Println("PoW Elapsed time:", elapsedTime)
-			return nonce, nil
-		}
-
-		nonce++
+	result, err := pow.Mine(ctx, transaction.PowPreimage(), difficulty, walletConfig.PowWorkers, walletConfig.PowMaxIterations)
+	log.Printf("PoW difficulty %d: %d hashes in %s (%.0f H/s)", difficulty, result.Attempts, result.Elapsed, result.HashRate())
+	if err != nil {
+		powFailures.Inc()
+		return 0, err
 	}
+	powSolveTime.Observe(result.Elapsed.Seconds())
 
-	return 0, fmt.Errorf("Proof of work failed after %d iterations", maxIterations)
+	return result.Nonce, nil
 }
 
 func postNewTransactionHandler(c *gin.Context) {
@@ -131,73 +198,78 @@ func postNewTransactionHandler(c *gin.Context) {
 	var transactionRequest request.NewTransactionWalletRequest
 
 	// Parse transaction request
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		log.Println("Error reading response body:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading response body"})
-		return
-	}
-
-	log.Println("Request Body:", string(body))
-
-	c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
-
 	if err := c.ShouldBindJSON(&transactionRequest); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// The seed must never reach the logs
+	log.Printf("Transaction request from %s to %s: %s tokens, type %s", transactionRequest.From, transactionRequest.To, transactionRequest.Token, transactionRequest.Type)
+
 	// Validate transaction request
 	if err := validateNewTransactionRequest(&transactionRequest); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction request", "details": err.Error()})
 		return
 	}
 
-	// Get selection tips from node
-	if node == "" {
-		node = getRandomNode()
-	}
-
-	nodeEndpoint := node + "/node/selectionTips"
-
-	selectionTipsResponse, err := http.Get(nodeEndpoint)
+	_, privateKey, err := entity.NewKeyPairFromSeed(transactionRequest.Seed)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to perform GET selection tips", "detail": err.Error()})
-		return
-	}
-	defer selectionTipsResponse.Body.Close()
-
-	if selectionTipsResponse.StatusCode != http.StatusOK {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unexpected status code", "detail": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction request", "details": err.Error()})
 		return
 	}
 
-	selectionTipsBody, err := io.ReadAll(selectionTipsResponse.Body)
+	// The sequence read below stays the last one of the sender until the
+	// transaction is submitted
+	ctx := c.Request.Context()
+	unlock, err := senderLocks.Lock(ctx, transactionRequest.From)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading selection tips response body", "detail": err.Error()})
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Request cancelled while waiting for the previous transaction of the sender", "detail": err.Error()})
 		return
 	}
+	defer unlock()
 
+	// The tips, the sequence and the difficulty must come from the same node
+	var stateNode string
 	var selectionTipsResponseDto response.SelectionTipsResponse
-	err = json.Unmarshal(selectionTipsBody, &selectionTipsResponseDto)
+	var sequence uint64
+	var difficultyAdjustment int
+	err = nodePool.Do(ctx, "", func(nodeURL string) error {
+		if err := callNode(ctx, http.MethodGet, nodeURL, "/node/selectionTips", nil, &selectionTipsResponseDto); err != nil {
+			return err
+		}
+
+		var err error
+		if sequence, err = getWalletSequence(ctx, nodeURL, transactionRequest.From); err != nil {
+			return err
+		}
+
+		if transactionRequest.Type == entity.TransactionTypeStandard {
+			if difficultyAdjustment, err = getDifficultyAdjustment(ctx, nodeURL); err != nil {
+				return err
+			}
+		}
+
+		stateNode = nodeURL
+		return nil
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse JSON response", "detail": err.Error()})
+		log.Println("Error getting the transaction state from a node:", err)
+		respondNodeError(c, "Failed to get selection tips, sequence and difficulty", err)
 		return
 	}
 
-	// Perform PoW if transaction type is standard
-	var nonce uint64
-	if transactionRequest.Type == entity.TransactionTypeStandard {
-		nonce, err = doProofOfWork(transactionRequest.Data, 1000000)
-	}
-
 	// Calculate fee if transaction type is fast
-	var fee float64 = 0.0
+	var fee entity.Amount
 	if transactionRequest.Type == entity.TransactionTypeFast {
 		fee = entity.CalculateFee(transactionRequest.Data)
 	}
 
-	// Send transaction to node
+	// Approve the selection tips
+	parents := make([]string, 0, len(selectionTipsResponseDto.SelectionTips))
+	for _, selectionTip := range selectionTipsResponseDto.SelectionTips {
+		parents = append(parents, selectionTip.ID)
+	}
+
 	newTransaction := entity.NewTransaction(
 		transactionRequest.From,
 		transactionRequest.To,
@@ -205,37 +277,65 @@ func postNewTransactionHandler(c *gin.Context) {
 		transactionRequest.Data,
 		transactionRequest.Type,
 		fee,
-		nonce)
+		0,
+		sequence+1,
+		parents)
+
+	// Perform PoW over the whole transaction if its type is standard
+	if transactionRequest.Type == entity.TransactionTypeStandard {
+		newTransaction.Nonce, err = doProofOfWork(ctx, &newTransaction, difficultyAdjustment)
+		if err != nil {
+			log.Println("Error performing PoW:", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to perform PoW", "detail": err.Error()})
+			return
+		}
+	}
+
+	// Send transaction to node, starting with the one the tips come from.
+	// Retries send the same signed transaction, so a node that accepted it
+	// without answering cannot make it count twice. Only when a node demands a
+	// higher difficulty is the transaction mined again, and the copy with the
+	// same sequence that a previous node may have accepted becomes a double
+	// spend of which the nodes keep one.
+	newTransaction.Sign(privateKey)
 
 	newTransactionNodeRequest := request.NewTransactionNodeRequest{Transaction: newTransaction, SelectionTips: selectionTipsResponseDto.SelectionTips}
 
-	nodeEndpoint = node + "/node/transaction"
+	var powErr error
+	err = nodePool.Do(ctx, stateNode, func(nodeURL string) error {
+		// Another node may demand a higher difficulty than the one mined for
+		if nodeURL != stateNode && transactionRequest.Type == entity.TransactionTypeStandard {
+			adjustment, err := getDifficultyAdjustment(ctx, nodeURL)
+			if err != nil {
+				return err
+			}
+			if adjustment > difficultyAdjustment {
+				if newTransaction.Nonce, powErr = doProofOfWork(ctx, &newTransaction, adjustment); powErr != nil {
+					return powErr
+				}
+				difficultyAdjustment = adjustment
+				newTransaction.Sign(privateKey)
+				newTransactionNodeRequest.Transaction = newTransaction
+			}
+		}
+		err := callNode(ctx, http.MethodPost, nodeURL, "/node/transaction", newTransactionNodeRequest, nil)
 
-	newTransactionNodeRequestJSON, err := json.Marshal(newTransactionNodeRequest)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to marshal JSON"})
+		// A node that already holds the transaction got it from a previous
+		// node that accepted it without answering
+		var rejected *nodeRejectedError
+		if errors.As(err, &rejected) && rejected.status == http.StatusConflict && existingTransactionID(rejected.body) == newTransaction.ID {
+			return nil
+		}
+		return err
+	})
+	if powErr != nil {
+		log.Println("Error performing PoW:", powErr)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to perform PoW", "detail": powErr.Error()})
 		return
 	}
-
-	execute, err := http.Post(nodeEndpoint, "application/json", bytes.NewBuffer(newTransactionNodeRequestJSON))
 	if err != nil {
-		log.Println("Error making request to node endpoint:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error making request to node endpoint", "detail": err.Error()})
-		return
-	}
-	defer execute.Body.Close()
-
-	if execute.StatusCode != http.StatusOK {
-		body, err := io.ReadAll(execute.Body)
-		if err != nil {
-			log.Println("Error reading response body:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading response body"})
-			return
-		}
-
-		log.Printf("Unexpected status code: %d", execute.StatusCode)
-		log.Println("Request Body:", string(body))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unexpected status code"})
+		log.Println("Error submitting the transaction:", err)
+		respondNodeError(c, "Failed to submit the transaction", err)
 		return
 	}
 
@@ -252,6 +352,15 @@ func validateNewTransactionRequest(newTransactionRequest *request.NewTransaction
 		return errors.New("invalid transaction FROM address")
 	}
 
+	seedAddress, err := entity.DeriveAddress(newTransactionRequest.Seed)
+	if err != nil {
+		return err
+	}
+
+	if seedAddress != newTransactionRequest.From {
+		return errors.New("transaction FROM address does not belong to the wallet seed")
+	}
+
 	if !entity.IsValidAddress(newTransactionRequest.To) {
 		return errors.New("invalid transaction TO address")
 	}
@@ -262,3 +371,41 @@ func validateNewTransactionRequest(newTransactionRequest *request.NewTransaction
 
 	return nil
 }
+
+// existingTransactionID returns the ID a node reports for a transaction it
+// already holds, empty for any other conflict.
+func existingTransactionID(body string) string {
+	var conflict struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(body), &conflict); err != nil {
+		return ""
+	}
+	return conflict.ID
+}
+
+// getWalletSequence returns the sequence of the last transaction a node
+// accepted from an address, zero when the node does not know the address yet.
+func getWalletSequence(ctx context.Context, nodeURL string, address string) (uint64, error) {
+	var walletResponseDto response.WalletResponse
+	err := callNode(ctx, http.MethodGet, nodeURL, "/node/wallet/"+address, nil, &walletResponseDto)
+
+	var rejected *nodeRejectedError
+	if errors.As(err, &rejected) && rejected.status == http.StatusNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return walletResponseDto.Sequence, nil
+}
+
+// getDifficultyAdjustment returns the proof of work difficulty adjustment a
+// node currently applies to submitted transactions.
+func getDifficultyAdjustment(ctx context.Context, nodeURL string) (int, error) {
+	var difficultyResponseDto response.DifficultyResponse
+	if err := callNode(ctx, http.MethodGet, nodeURL, "/node/difficulty", nil, &difficultyResponseDto); err != nil {
+		return 0, err
+	}
+	return difficultyResponseDto.Adjustment, nil
+}